@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Tool decides how the points of a stroke are applied to the canvas
+type Tool uint8
+
+const (
+	ToolPen    Tool = iota // paint the stroke color
+	ToolEraser             // paint the canvas background over whatever is underneath
+)
+
+// color of an empty canvas, eraser strokes are painted with it
+var canvasBackground = rl.Black
+
+// strokeKey identifies a stroke across every client in a room (stroke ids are only unique per author)
+type strokeKey struct {
+	Author uint32
+	ID     uint32
+}
+
+// Stroke is one continuous line drawn by a single client, from mouse press to mouse release.
+// The style is fixed when the stroke starts so changing tools never restyles existing ink
+type Stroke struct {
+	Author uint32   // client id of whoever drew the stroke
+	ID     uint32   // unique per author
+	Tool   Tool     // pen or eraser
+	Radius float32  // radius of every circle in the stroke
+	Color  rl.Color // ink color
+	Time   int64    // unix milliseconds when the stroke was started
+	Points []rl.Vector2
+	Undone bool // undone strokes are kept in the history so they can be redone, but are not rendered
+}
+
+// StrokeDelta carries the points appended to a stroke since the last update. The style is repeated in every delta so a
+// receiver can render a stroke from whichever delta reaches it first
+type StrokeDelta struct {
+	Author uint32 // not part of the payload, filled in from the envelope sender
+	Stroke uint32
+	Seq    uint32 // index of the first point of this delta within the stroke
+	Tool   Tool
+	Radius float32
+	Color  rl.Color
+	Time   int64
+	Points []rl.Vector2
+}
+
+// fixed size header written in front of the points of a delta
+type strokeDeltaHeader struct {
+	Stroke uint32
+	Seq    uint32
+	Time   int64
+	Tool   Tool
+	Radius float32
+	Color  rl.Color
+	Count  uint32
+}
+
+// fixed size header written in front of the points of every stroke in a canvas snapshot
+type strokeHeader struct {
+	Author uint32
+	ID     uint32
+	Tool   Tool
+	Radius float32
+	Color  rl.Color
+	Time   int64
+	Undone bool
+	Count  uint32
+}
+
+// Canvas stores every stroke in the order it was started. It is not safe for concurrent use, callers guard it with their own mutex
+type Canvas struct {
+	strokes []*Stroke
+	index   map[strokeKey]*Stroke
+}
+
+func NewCanvas() *Canvas {
+	return &Canvas{index: make(map[strokeKey]*Stroke)}
+}
+
+// Merge appends the points of a delta to its stroke, creating the stroke if this is the first time it is seen.
+// Points that are already known (the delta overlaps the stroke) are skipped so a replayed delta is harmless
+func (c *Canvas) Merge(d StrokeDelta) {
+	key := strokeKey{Author: d.Author, ID: d.Stroke}
+
+	s, ok := c.index[key]
+	if !ok {
+		s = &Stroke{Author: d.Author, ID: d.Stroke, Tool: d.Tool, Radius: d.Radius, Color: d.Color, Time: d.Time}
+		c.index[key] = s
+		c.strokes = append(c.strokes, s)
+	}
+
+	points := d.Points
+	known := uint32(len(s.Points))
+	if d.Seq < known {
+		overlap := known - d.Seq
+		if overlap >= uint32(len(points)) {
+			return
+		}
+		points = points[overlap:]
+	}
+
+	s.Points = append(s.Points, points...)
+}
+
+// InkColor is the color the stroke is rendered with, eraser strokes mask the ink below them with the background
+func (s *Stroke) InkColor() rl.Color {
+	if s.Tool == ToolEraser {
+		return canvasBackground
+	}
+	return s.Color
+}
+
+// SetUndone hides or shows again one of the strokes. Returns false when the stroke is not on the canvas (e.g. it was cleared)
+func (c *Canvas) SetUndone(author, id uint32, undone bool) bool {
+	s, ok := c.index[strokeKey{Author: author, ID: id}]
+	if !ok {
+		return false
+	}
+
+	s.Undone = undone
+	return true
+}
+
+// Has reports whether the canvas holds the stroke
+func (c *Canvas) Has(author, id uint32) bool {
+	_, ok := c.index[strokeKey{Author: author, ID: id}]
+	return ok
+}
+
+// Clear removes every stroke from the canvas
+func (c *Canvas) Clear() {
+	c.strokes = nil
+	c.index = make(map[strokeKey]*Stroke)
+}
+
+// Strokes returns the strokes in drawing order. The returned strokes must not be modified
+func (c *Canvas) Strokes() []*Stroke {
+	return c.strokes
+}
+
+// IsEmpty is true when there is nothing to render, undone strokes dont count
+func (c *Canvas) IsEmpty() bool {
+	for _, s := range c.strokes {
+		if !s.Undone && len(s.Points) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encode the whole canvas, undone strokes included, as [stroke count] followed by every stroke header and its points
+func (c *Canvas) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	if err := binary.Write(buf, binary.LittleEndian, uint32(len(c.strokes))); err != nil {
+		return nil, err
+	}
+
+	for _, s := range c.strokes {
+		header := strokeHeader{
+			Author: s.Author,
+			ID:     s.ID,
+			Tool:   s.Tool,
+			Radius: s.Radius,
+			Color:  s.Color,
+			Time:   s.Time,
+			Undone: s.Undone,
+			Count:  uint32(len(s.Points)),
+		}
+		if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(buf, binary.LittleEndian, s.Points); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode a canvas written by MarshalBinary, replacing everything currently on the canvas
+func (c *Canvas) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return fmt.Errorf("reading stroke count: %w", err)
+	}
+
+	elemSize := binary.Size(rl.Vector2{})
+	decoded := NewCanvas()
+
+	for i := uint32(0); i < count; i++ {
+		var header strokeHeader
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			return fmt.Errorf("reading header of stroke %d: %w", i, err)
+		}
+
+		// check the size before allocating so a corrupt count cant make us allocate gigabytes
+		if int(header.Count)*elemSize > r.Len() {
+			return fmt.Errorf("stroke %d claims %d points but only %d bytes remain", i, header.Count, r.Len())
+		}
+
+		points := make([]rl.Vector2, header.Count)
+		if err := binary.Read(r, binary.LittleEndian, points); err != nil {
+			return fmt.Errorf("reading points of stroke %d: %w", i, err)
+		}
+
+		s := &Stroke{
+			Author: header.Author,
+			ID:     header.ID,
+			Tool:   header.Tool,
+			Radius: header.Radius,
+			Color:  header.Color,
+			Time:   header.Time,
+			Undone: header.Undone,
+			Points: points,
+		}
+		decoded.strokes = append(decoded.strokes, s)
+		decoded.index[strokeKey{Author: s.Author, ID: s.ID}] = s
+	}
+
+	if r.Len() != 0 {
+		return fmt.Errorf("%d trailing bytes after %d strokes", r.Len(), count)
+	}
+
+	*c = *decoded
+	return nil
+}
+
+// encode a delta into its little endian wire representation
+func (d StrokeDelta) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	header := strokeDeltaHeader{
+		Stroke: d.Stroke,
+		Seq:    d.Seq,
+		Time:   d.Time,
+		Tool:   d.Tool,
+		Radius: d.Radius,
+		Color:  d.Color,
+		Count:  uint32(len(d.Points)),
+	}
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(buf, binary.LittleEndian, d.Points); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode a delta written by MarshalBinary (the author is left unset), making sure the point count matches the payload size
+func (d *StrokeDelta) UnmarshalBinary(msg []byte) error {
+	r := bytes.NewReader(msg)
+
+	var header strokeDeltaHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("reading delta header: %w", err)
+	}
+
+	elemSize := binary.Size(rl.Vector2{})
+	if r.Len() != int(header.Count)*elemSize {
+		return fmt.Errorf("invalid delta payload size: points=%d remaining=%d elem=%d", header.Count, r.Len(), elemSize)
+	}
+
+	points := make([]rl.Vector2, header.Count)
+	if err := binary.Read(r, binary.LittleEndian, points); err != nil {
+		return fmt.Errorf("reading delta points: %w", err)
+	}
+
+	*d = StrokeDelta{
+		Stroke: header.Stroke,
+		Seq:    header.Seq,
+		Tool:   header.Tool,
+		Radius: header.Radius,
+		Color:  header.Color,
+		Time:   header.Time,
+		Points: points,
+	}
+	return nil
+}
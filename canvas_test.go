@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// points (i, i) for i in [from, to)
+func testPoints(from, to int) []rl.Vector2 {
+	var points []rl.Vector2
+	for i := from; i < to; i++ {
+		points = append(points, rl.NewVector2(float32(i), float32(i)))
+	}
+	return points
+}
+
+func testDelta(seq, from, to int) StrokeDelta {
+	return StrokeDelta{Author: 1, Stroke: 1, Seq: uint32(seq), Radius: 5, Color: rl.White, Points: testPoints(from, to)}
+}
+
+func TestCanvasMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []StrokeDelta
+		want   int // points of the stroke, always 0..want-1 in order
+	}{
+		{"single delta", []StrokeDelta{testDelta(0, 0, 3)}, 3},
+		{"consecutive deltas", []StrokeDelta{testDelta(0, 0, 3), testDelta(3, 3, 5)}, 5},
+		{"replayed delta", []StrokeDelta{testDelta(0, 0, 3), testDelta(0, 0, 3)}, 3},
+		{"overlapping delta", []StrokeDelta{testDelta(0, 0, 3), testDelta(1, 1, 6)}, 6},
+		{"delta inside the stroke", []StrokeDelta{testDelta(0, 0, 5), testDelta(1, 1, 3)}, 5},
+		{"replay after more points", []StrokeDelta{testDelta(0, 0, 3), testDelta(3, 3, 5), testDelta(0, 0, 3)}, 5},
+		{"empty delta", []StrokeDelta{testDelta(0, 0, 0)}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCanvas()
+			for _, d := range tt.deltas {
+				c.Merge(d)
+			}
+
+			strokes := c.Strokes()
+			if len(strokes) != 1 {
+				t.Fatalf("got %d strokes, want 1", len(strokes))
+			}
+
+			got := strokes[0].Points
+			if len(got) != tt.want {
+				t.Fatalf("got %d points, want %d", len(got), tt.want)
+			}
+			for i, p := range got {
+				if p.X != float32(i) {
+					t.Fatalf("point %d = %v, want x %d", i, p, i)
+				}
+			}
+		})
+	}
+}
+
+func TestCanvasMergeKeepsAuthorsApart(t *testing.T) {
+	c := NewCanvas()
+	c.Merge(testDelta(0, 0, 2))
+
+	other := testDelta(0, 0, 4)
+	other.Author = 2
+	c.Merge(other)
+
+	if len(c.Strokes()) != 2 {
+		t.Fatalf("got %d strokes, want one per author", len(c.Strokes()))
+	}
+}
+
+func TestCanvasHas(t *testing.T) {
+	c := NewCanvas()
+	c.Merge(testDelta(0, 0, 1))
+
+	if !c.Has(1, 1) {
+		t.Error("Has() = false for a merged stroke")
+	}
+	if c.Has(2, 1) || c.Has(1, 2) {
+		t.Error("Has() = true for a stroke that was never merged")
+	}
+
+	c.Clear()
+	if c.Has(1, 1) {
+		t.Error("Has() = true after Clear()")
+	}
+}
+
+func TestCanvasRoundTrip(t *testing.T) {
+	c := NewCanvas()
+	c.Merge(testDelta(0, 0, 3))
+	c.Merge(StrokeDelta{Author: 2, Stroke: 9, Tool: ToolEraser, Radius: 20, Time: 1234, Points: testPoints(0, 2)})
+	c.SetUndone(2, 9, true)
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := NewCanvas()
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.Strokes(), c.Strokes()) {
+		t.Fatalf("decoded strokes = %+v, want %+v", decoded.Strokes(), c.Strokes())
+	}
+}
+
+func TestCanvasUnmarshalBadInput(t *testing.T) {
+	c := NewCanvas()
+	c.Merge(testDelta(0, 0, 3))
+	valid, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stroke count far larger than the data
+	huge := binary.LittleEndian.AppendUint32(nil, 1<<31)
+
+	// one stroke whose header claims more points than follow
+	lying := append([]byte(nil), valid...)
+	countOffset := 4 + binary.Size(strokeHeader{}) - 4
+	binary.LittleEndian.PutUint32(lying[countOffset:], 1<<30)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short count", valid[:2]},
+		{"truncated header", valid[:10]},
+		{"truncated points", valid[:len(valid)-1]},
+		{"trailing bytes", append(append([]byte(nil), valid...), 0)},
+		{"huge stroke count", huge},
+		{"huge point count", lying},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := NewCanvas()
+			decoded.Merge(testDelta(0, 0, 1))
+
+			if err := decoded.UnmarshalBinary(tt.data); err == nil {
+				t.Fatal("UnmarshalBinary() succeeded, want an error")
+			}
+
+			// a failed decode leaves the canvas as it was
+			if len(decoded.Strokes()) != 1 || len(decoded.Strokes()[0].Points) != 1 {
+				t.Fatal("failed UnmarshalBinary() changed the canvas")
+			}
+		})
+	}
+}
+
+func TestStrokeDeltaRoundTrip(t *testing.T) {
+	// the author comes from the envelope, not the payload
+	d := StrokeDelta{Stroke: 4, Seq: 17, Tool: ToolEraser, Radius: 10, Color: rl.Red, Time: 99, Points: testPoints(0, 5)}
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got StrokeDelta
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("decoded delta = %+v, want %+v", got, d)
+	}
+}
+
+func TestStrokeDeltaUnmarshalBadInput(t *testing.T) {
+	valid, err := testDelta(0, 0, 3).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// header claiming more points than the payload holds
+	lying := append([]byte(nil), valid...)
+	countOffset := binary.Size(strokeDeltaHeader{}) - 4
+	binary.LittleEndian.PutUint32(lying[countOffset:], 1<<30)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated header", valid[:5]},
+		{"truncated points", valid[:len(valid)-3]},
+		{"extra bytes", append(append([]byte(nil), valid...), 1, 2, 3, 4)},
+		{"huge point count", lying},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d StrokeDelta
+			if err := d.UnmarshalBinary(tt.data); err == nil {
+				t.Fatal("UnmarshalBinary() succeeded, want an error")
+			}
+		})
+	}
+}
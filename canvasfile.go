@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// CanvasFormatVersion is bumped whenever the on disk layout changes. Files from older versions stay readable
+const CanvasFormatVersion uint16 = 1
+
+// extension of saved canvases
+const canvasFileExt = ".picto"
+
+// every canvas file starts with these bytes
+var canvasFileMagic = []byte("PICTO")
+
+var ErrNotCanvasFile = errors.New("not a Picto-Chat canvas file")
+
+// header written in front of the canvas, after the magic bytes
+type canvasFileHeader struct {
+	Version uint16
+	SavedAt int64 // unix milliseconds
+}
+
+// SaveCanvasFile writes the canvas (strokes, styles, authors, timestamps and undo history) to path as
+// [magic][format version][saved at][marshalled Canvas]
+func SaveCanvasFile(path string, c *Canvas) error {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(canvasFileMagic)
+
+	header := canvasFileHeader{Version: CanvasFormatVersion, SavedAt: time.Now().UnixMilli()}
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	buf.Write(data)
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// LoadCanvasFile reads a canvas written by SaveCanvasFile
+func LoadCanvasFile(path string) (*Canvas, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, canvasFileMagic) {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotCanvasFile)
+	}
+
+	r := bytes.NewReader(data[len(canvasFileMagic):])
+
+	var header canvasFileHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+
+	if header.Version > CanvasFormatVersion {
+		return nil, fmt.Errorf("%s was saved by a newer Picto-Chat (format v%d, this app reads up to v%d)", path, header.Version, CanvasFormatVersion)
+	}
+
+	canvas := NewCanvas()
+	if err := canvas.UnmarshalBinary(data[len(data)-r.Len():]); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return canvas, nil
+}
+
+// most recently modified canvas file in dir, used by the open shortcut since there is no file dialog
+func latestCanvasFile(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+canvasFileExt))
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latestTime) {
+			latest = m
+			latestTime = info.ModTime()
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no %s files in %s", canvasFileExt, dir)
+	}
+
+	return latest, nil
+}
+
+// the first dropped file that looks like a saved canvas
+func droppedCanvasFile(files []string) (string, bool) {
+	for _, f := range files {
+		if strings.EqualFold(filepath.Ext(f), canvasFileExt) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Ctrl+S saves the canvas to a timestamped file, Ctrl+O (host only) opens the most recent save in the room
+func (a *App) OnSaveOpenPressed() {
+	if !isCtrlDown() {
+		return
+	}
+
+	if rl.IsKeyPressed(rl.KeyS) {
+		path, err := a.SaveCanvas()
+		if err != nil {
+			a.ShowNotice(fmt.Sprintf("Save failed: %v", err))
+			return
+		}
+		a.ShowNotice(fmt.Sprintf("Saved %s", path))
+	}
+
+	if rl.IsKeyPressed(rl.KeyO) {
+		path, err := latestCanvasFile(".")
+		if err != nil {
+			a.ShowNotice(fmt.Sprintf("Open failed: %v", err))
+			return
+		}
+		a.OpenCanvasInRoom(path)
+	}
+}
+
+// SaveCanvas writes the canvas to a timestamped file in the working directory and returns its path
+func (a *App) SaveCanvas() (string, error) {
+	path := exportFileName(strings.TrimPrefix(canvasFileExt, "."))
+
+	a.mu.RLock()
+	err := SaveCanvasFile(path, a.canvas)
+	a.mu.RUnlock()
+
+	if err != nil {
+		fmt.Printf("failed to save canvas: %v\n", err)
+		return "", err
+	}
+
+	fmt.Printf("Saved canvas to %s\n", path)
+	return path, nil
+}
+
+// handle canvas files dropped on the window: picked for the next room on the menu, opened in the room while hosting
+func (a *App) OnFileDropped() {
+	if !rl.IsFileDropped() {
+		return
+	}
+
+	files := rl.LoadDroppedFiles()
+	rl.UnloadDroppedFiles()
+
+	path, ok := droppedCanvasFile(files)
+	if !ok {
+		a.ShowNotice(fmt.Sprintf("Only %s files can be opened", canvasFileExt))
+		return
+	}
+
+	switch a.currentAppState {
+	case AppStateRoomConfig, AppStateRoomSetup:
+		a.openCanvasPath = path
+		a.joinErr = ""
+
+	case AppStateDrawStart, AppStateDrawing:
+		a.OpenCanvasInRoom(path)
+	}
+}
+
+// replace the room canvas with a saved one. Only the host may do this, the host relays the canvas to everyone
+func (a *App) OpenCanvasInRoom(path string) {
+	if !a.isRoomHost {
+		a.ShowNotice("Only the host can open a canvas in the room")
+		return
+	}
+
+	canvas, err := LoadCanvasFile(path)
+	if err != nil {
+		fmt.Printf("failed to open canvas: %v\n", err)
+		a.ShowNotice(fmt.Sprintf("Open failed: %v", err))
+		return
+	}
+
+	payload, err := canvas.MarshalBinary()
+	if err != nil {
+		a.ShowNotice(fmt.Sprintf("Open failed: %v", err))
+		return
+	}
+
+	// queued behind our pending points so they are replaced too
+	a.mu.Lock()
+	a.queueMessage(MsgSnapshot, payload)
+	a.mu.Unlock()
+
+	a.ShowNotice(fmt.Sprintf("Opened %s", filepath.Base(path)))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPort = 8000
+	defaultBind = "0.0.0.0"
+
+	defaultSendRate = 20
+	maxSendRate     = 240
+)
+
+// Config holds the settings picked on the command line or in the config file when the app is started.
+// Command line flags win over the config file
+type Config struct {
+	HostOnlyClear bool     `json:"hostOnlyClear"` // only the host of a room may clear the canvas for everyone
+	OpenCanvas    string   `json:"openCanvas"`    // saved canvas shown in the next room we make
+	Port          int      `json:"port"`          // port the room WebSocket server listens on and advertises over mDNS
+	Bind          string   `json:"bind"`          // interface address the room WebSocket server listens on
+	Rooms         []string `json:"rooms"`         // extra rooms served in the background, nobody hosts them from a window
+	Headless      bool     `json:"headless"`      // serve the rooms without opening a window
+	SendRate      int      `json:"sendRate"`      // times per second drawing updates are sent to the room
+	ConvertSVG    string   `json:"-"`             // saved canvas to convert to SVG without opening a window
+}
+
+// config file read when -config isnt given, e.g. ~/.config/picto-chat/config.json
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "picto-chat", "config.json")
+}
+
+func ParseConfig() (Config, error) {
+	cfg := Config{Port: defaultPort, Bind: defaultBind, SendRate: defaultSendRate}
+
+	configPath := flag.String("config", defaultConfigPath(), "JSON config `file` with the same settings as the flags")
+	flag.BoolVar(&cfg.HostOnlyClear, "host-only-clear", cfg.HostOnlyClear, "only let the host clear the canvas in rooms you make")
+	flag.StringVar(&cfg.OpenCanvas, "open", cfg.OpenCanvas, "saved `canvas` (.picto) to open in the room you make")
+	flag.IntVar(&cfg.Port, "port", cfg.Port, "`port` rooms you make listen on and are discovered on")
+	flag.StringVar(&cfg.Bind, "bind", cfg.Bind, "interface `address` rooms you make listen on")
+	flag.Var((*listFlag)(&cfg.Rooms), "rooms", "comma separated `names` of extra rooms to serve in the background")
+	flag.BoolVar(&cfg.Headless, "headless", cfg.Headless, "serve rooms without opening a window, -rooms names them and -open is loaded into the first")
+	flag.IntVar(&cfg.SendRate, "send-rate", cfg.SendRate, "`times` per second drawing updates are sent to the room")
+	flag.StringVar(&cfg.ConvertSVG, "svg", "", "convert a saved `canvas` (.picto) to an SVG next to it and exit")
+	flag.Parse()
+
+	if err := cfg.loadFile(*configPath); err != nil {
+		return cfg, err
+	}
+
+	// parse again so flags given on the command line override the config file
+	flag.CommandLine.Parse(os.Args[1:])
+
+	return cfg, cfg.validate()
+}
+
+// merge the settings of a JSON config file into cfg. A missing file is fine, the defaults are used
+func (cfg *Config) loadFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (cfg Config) validate() error {
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid port %d", cfg.Port)
+	}
+
+	if cfg.SendRate < 1 || cfg.SendRate > maxSendRate {
+		return fmt.Errorf("invalid send rate %d, expected 1 to %d updates per second", cfg.SendRate, maxSendRate)
+	}
+
+	if cfg.Bind != "" && net.ParseIP(cfg.Bind) == nil {
+		return fmt.Errorf("invalid bind address %q, expected an IP", cfg.Bind)
+	}
+
+	return nil
+}
+
+// host:port the room WebSocket server listens on
+func (cfg Config) ListenAddr() string {
+	return net.JoinHostPort(cfg.Bind, strconv.Itoa(cfg.Port))
+}
+
+// specific address we are bound to, nil when listening on every interface
+func (cfg Config) bindIP() net.IP {
+	ip := net.ParseIP(cfg.Bind)
+	if ip == nil || ip.IsUnspecified() {
+		return nil
+	}
+	return ip
+}
+
+// URL the host's own window dials to join the room it just made
+func (cfg Config) SelfURL(path string) string {
+	host := "127.0.0.1"
+	if ip := cfg.bindIP(); ip != nil {
+		host = ip.String()
+	}
+	return fmt.Sprintf("ws://%s%s", net.JoinHostPort(host, strconv.Itoa(cfg.Port)), path)
+}
+
+// comma separated list flag. Set replaces the whole list so parsing the command line twice doesnt duplicate entries
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = nil
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// how long the result of an export stays on screen
+const noticeDuration = 4 * time.Second
+
+// timestamped file name for an export, e.g. picto-chat-20250101-120000.png
+func exportFileName(ext string) string {
+	return fmt.Sprintf("picto-chat-%s.%s", time.Now().Format("20060102-150405"), ext)
+}
+
+// render strokes with their own style, skipping the undone ones. Used for the window and for offscreen exports
+func drawStrokes(strokes []*Stroke) {
+	for _, s := range strokes {
+		if s.Undone {
+			continue
+		}
+		for _, p := range s.Points {
+			rl.DrawCircle(int32(p.X), int32(p.Y), s.Radius, s.InkColor())
+		}
+	}
+}
+
+// ExportPNG renders the canvas into an offscreen texture (no HUD or tools) and writes it to a timestamped PNG in the
+// working directory. Must be called from the render thread
+func (a *App) ExportPNG() (string, error) {
+	target := rl.LoadRenderTexture(screenWidth, screenHeight)
+	defer rl.UnloadRenderTexture(target)
+
+	rl.BeginTextureMode(target)
+	rl.ClearBackground(canvasBackground)
+	a.DrawCanvas()
+	rl.EndTextureMode()
+
+	img := rl.LoadImageFromTexture(target.Texture)
+	defer rl.UnloadImage(img)
+
+	// OpenGL render textures are stored bottom up
+	rl.ImageFlipVertical(img)
+
+	path := exportFileName("png")
+	if !rl.ExportImage(*img, path) {
+		return "", fmt.Errorf("failed to write %s", path)
+	}
+
+	return path, nil
+}
+
+// Ctrl+E exports the canvas as a PNG, Ctrl+Shift+E as an SVG
+func (a *App) OnExportPressed() {
+	if !rl.IsKeyPressed(rl.KeyE) || !isCtrlDown() {
+		return
+	}
+
+	var path string
+	var err error
+	if isShiftDown() {
+		path, err = a.ExportSVG()
+	} else {
+		path, err = a.ExportPNG()
+	}
+
+	if err != nil {
+		fmt.Printf("failed to export canvas: %v\n", err)
+		a.ShowNotice(fmt.Sprintf("Export failed: %v", err))
+		return
+	}
+
+	fmt.Printf("Exported canvas to %s\n", path)
+	a.ShowNotice(fmt.Sprintf("Saved %s", path))
+}
+
+// show a short message on top of the canvas for a few seconds. Also called from the room connection goroutine
+func (a *App) ShowNotice(msg string) {
+	a.mu.Lock()
+	a.notice = msg
+	a.noticeUntil = time.Now().Add(noticeDuration)
+	a.mu.Unlock()
+}
+
+func (a *App) DrawNotice() {
+	a.mu.RLock()
+	notice := a.notice
+	until := a.noticeUntil
+	a.mu.RUnlock()
+
+	if notice == "" || time.Now().After(until) {
+		return
+	}
+
+	drawTextCentered(a.font.Italic, notice, 110, 25, rl.Green)
+}
+
+// list of the less common shortcuts in the bottom right corner
+func (a *App) DrawShortcutLegend() {
+	shortcuts := []string{
+		"[Ctrl+E] Export PNG",
+		"[Ctrl+Shift+E] Export SVG",
+		"[Ctrl+S] Save canvas",
+		"[Ctrl+O] Open last save (host)",
+		"[H] Hand room over and leave (host)",
+	}
+
+	y := float32(screenHeight - 60 - len(shortcuts)*30)
+	for _, s := range shortcuts {
+		rl.DrawTextEx(a.font.Italic, s, rl.NewVector2(screenWidth-440, y), 25, 2, rl.Gray)
+		y += 30
+	}
+}
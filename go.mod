@@ -6,11 +6,11 @@ require (
 	github.com/gen2brain/raylib-go/raylib v0.55.1
 	github.com/gorilla/websocket v1.5.3
 	github.com/hashicorp/mdns v1.0.6
+	github.com/miekg/dns v1.1.55
 )
 
 require (
 	github.com/ebitengine/purego v0.9.1 // indirect
-	github.com/miekg/dns v1.1.55 // indirect
 	golang.org/x/exp v0.0.0-20251113190631-e25ba8c21ef6 // indirect
 	golang.org/x/mod v0.30.0 // indirect
 	golang.org/x/net v0.47.0 // indirect
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// room served by -headless when no -rooms are given
+const defaultHeadlessRoom = "Picto-Chat"
+
+// RunHeadless serves rooms without opening a window until interrupted. Nobody hosts the rooms, everyone who joins is
+// an equal client and the canvas lives on the server, so the room outlasts whoever drew in it
+func RunHeadless(cfg Config) error {
+	names := cfg.Rooms
+	if len(names) == 0 {
+		names = []string{defaultHeadlessRoom}
+	}
+
+	// the first room starts from the saved canvas, if one was given
+	var canvas *Canvas
+	if cfg.OpenCanvas != "" {
+		var err error
+		canvas, err = LoadCanvasFile(cfg.OpenCanvas)
+		if err != nil {
+			return err
+		}
+	}
+
+	rs, err := NewRoomServer(cfg)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		start := canvas
+		if i > 0 {
+			start = nil
+		}
+
+		if _, err := rs.AddRoom(RoomSettings{Name: name}, start); err != nil {
+			rs.Shutdown(context.Background())
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Serving %d room(s) headless on %s, Ctrl+C to stop\n", len(names), cfg.ListenAddr())
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return rs.Shutdown(shutdownCtx)
+}
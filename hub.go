@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrHubClosed is returned when joining a room that is shutting down
+var ErrHubClosed = errors.New("room is closed")
+
+// ErrIDTaken is returned when joining with the id of a connected client without its rejoin secret
+var ErrIDTaken = errors.New("client id is already in the room")
+
+// Hub owns the connections to one room and its authoritative canvas. Joins, leaves and relayed messages all go through
+// it, so every connection sees the changes to the canvas in the order they were applied
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*websocket.Conn]*roomClient
+	canvas  *Canvas
+	closed  bool
+
+	// called whenever someone joins or leaves, outside of the lock
+	OnChange func()
+}
+
+func NewHub(canvas *Canvas) *Hub {
+	if canvas == nil {
+		canvas = NewCanvas()
+	}
+	return &Hub{clients: make(map[*websocket.Conn]*roomClient), canvas: canvas}
+}
+
+// Join adds a connection that completed the handshake. rejoin is the secret the client presented in its hello, secret
+// the one it was given in its welcome for its next reconnect. The whole canvas is queued before any live update, then
+// everyone gets the new participant list
+func (h *Hub) Join(ws *websocket.Conn, p Participant, rejoin string, secret string) (*roomClient, error) {
+	h.mu.Lock()
+
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrHubClosed
+	}
+
+	snapshot, err := h.canvas.MarshalBinary()
+	var msg []byte
+	if err == nil {
+		msg, err = NewEnvelope(MsgSnapshot, 0, snapshot).MarshalBinary()
+	}
+	if err != nil {
+		h.mu.Unlock()
+		return nil, fmt.Errorf("encoding canvas snapshot: %w", err)
+	}
+
+	if err := h.checkID(p.ID, rejoin); err != nil {
+		h.mu.Unlock()
+		return nil, err
+	}
+
+	// a client that reconnected before its old connection timed out replaces it
+	for conn, other := range h.clients {
+		if other.participant.ID == p.ID {
+			other.stop()
+			delete(h.clients, conn)
+		}
+	}
+
+	p.Joined = time.Now().UnixMilli()
+	client := newRoomClient(ws, p)
+	client.rejoin = secret
+	client.enqueue(msg)
+	h.clients[ws] = client
+	h.broadcastPresence(0, false)
+	h.mu.Unlock()
+
+	h.changed()
+	return client, nil
+}
+
+// Admit checks a client may join with id, presenting rejoin from its hello, before it is welcomed. Join checks again
+func (h *Hub) Admit(id uint32, rejoin string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return ErrHubClosed
+	}
+	return h.checkID(id, rejoin)
+}
+
+// an id already in the room may only come back with the rejoin secret of its connection. Ids are public, without the
+// secret anyone could take over the connection of another client by claiming its id. Caller must hold h.mu
+func (h *Hub) checkID(id uint32, rejoin string) error {
+	for _, other := range h.clients {
+		if other.participant.ID != id {
+			continue
+		}
+		if other.rejoin == "" || subtle.ConstantTimeCompare([]byte(rejoin), []byte(other.rejoin)) != 1 {
+			return ErrIDTaken
+		}
+	}
+	return nil
+}
+
+// Leave removes a client and tells everyone still in the room. timedOut is set when it stopped answering pings
+func (h *Hub) Leave(client *roomClient, timedOut bool) {
+	h.mu.Lock()
+
+	// already gone when it was replaced by a reconnect or the hub was closed
+	if h.clients[client.ws] != client {
+		h.mu.Unlock()
+		client.stop()
+		return
+	}
+	delete(h.clients, client.ws)
+	client.stop()
+
+	h.broadcastPresence(client.participant.ID, timedOut)
+	h.mu.Unlock()
+
+	h.changed()
+}
+
+// Relay applies a message to the canvas and queues it for every connection. The sender is skipped unless echo is
+// set, it already applied the message itself
+func (h *Hub) Relay(from *roomClient, msg []byte, echo bool, apply func(c *Canvas)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	apply(h.canvas)
+	for _, client := range h.clients {
+		if client == from && !echo {
+			continue
+		}
+		h.sendTo(client, msg)
+	}
+}
+
+// Count is the number of connections in the room
+func (h *Hub) Count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Close tells everyone the room ended with MsgRoomClosed, then disconnects them with a close frame carrying the
+// reason, after whatever was already queued for them. Nobody can join afterwards
+func (h *Hub) Close(reason string) {
+	h.mu.Lock()
+	h.closed = true
+	clients := h.clients
+	h.clients = make(map[*websocket.Conn]*roomClient)
+	h.mu.Unlock()
+
+	msg, err := NewEnvelope(MsgRoomClosed, 0, []byte(reason)).MarshalBinary()
+	if err != nil {
+		fmt.Printf("failed to encode room closed message: %v\n", err)
+	}
+
+	h.disconnect(clients, func(*roomClient) []byte { return msg }, websocket.CloseGoingAway, reason)
+}
+
+// Handoff passes the room on to the participant picked by electSuccessor, never the host leaving it. The successor
+// gets the canvas in its MsgHandoff, everyone else learns who to reconnect to, then they are all disconnected like
+// Close does. Returns false without touching anyone when nobody is left to take over
+func (h *Hub) Handoff(handoff Handoff, host uint32) bool {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return false
+	}
+
+	participants := make([]Participant, 0, len(h.clients))
+	for _, client := range h.clients {
+		participants = append(participants, client.participant)
+	}
+
+	successor, ok := electSuccessor(participants, map[uint32]bool{host: true})
+	if !ok {
+		h.mu.Unlock()
+		return false
+	}
+
+	canvas, err := h.canvas.MarshalBinary()
+	if err != nil {
+		h.mu.Unlock()
+		fmt.Printf("failed to encode canvas for handoff: %v\n", err)
+		return false
+	}
+
+	h.closed = true
+	clients := h.clients
+	h.clients = make(map[*websocket.Conn]*roomClient)
+	h.mu.Unlock()
+
+	handoff.Successor = successor
+	others := handoff
+	others.Password = ""
+	handoff.Canvas = canvas
+
+	// clients that dont get their handoff still elect the same successor once they cant reach us
+	toSuccessor, toOthers := encodeHandoff(handoff), encodeHandoff(others)
+
+	fmt.Printf("Handing the room over to client %d at %s\n", successor.ID, successor.Addr)
+	h.disconnect(clients, func(client *roomClient) []byte {
+		if client.participant.ID == successor.ID {
+			return toSuccessor
+		}
+		return toOthers
+	}, websocket.CloseServiceRestart, "host handed the room over")
+
+	return true
+}
+
+func encodeHandoff(handoff Handoff) []byte {
+	payload, err := json.Marshal(handoff)
+	if err != nil {
+		fmt.Printf("failed to encode handoff: %v\n", err)
+		return nil
+	}
+
+	msg, err := NewEnvelope(MsgHandoff, 0, payload).MarshalBinary()
+	if err != nil {
+		fmt.Printf("failed to encode handoff: %v\n", err)
+		return nil
+	}
+	return msg
+}
+
+// queue a last message for each client, nil for none, then close them all. Returns once the close frames are out
+func (h *Hub) disconnect(clients map[*websocket.Conn]*roomClient, last func(*roomClient) []byte, code int, reason string) {
+	for _, client := range clients {
+		if msg := last(client); msg != nil {
+			client.enqueue(msg)
+		}
+		client.close(code, reason)
+	}
+
+	// wait for the close frames to go out, a headless server exits right after closing its rooms
+	deadline := time.After(closeGrace)
+	for _, client := range clients {
+		select {
+		case <-client.finished:
+		case <-deadline:
+		}
+	}
+
+	if len(clients) > 0 {
+		h.changed()
+	}
+}
+
+func (h *Hub) changed() {
+	if h.OnChange != nil {
+		h.OnChange()
+	}
+}
+
+// send everyone the participant list. Caller must hold h.mu
+func (h *Hub) broadcastPresence(left uint32, timedOut bool) {
+	presence := Presence{Left: left, TimedOut: timedOut}
+	for _, client := range h.clients {
+		presence.Participants = append(presence.Participants, client.participant)
+	}
+	sort.Slice(presence.Participants, func(i, j int) bool {
+		return presence.Participants[i].Joined < presence.Participants[j].Joined
+	})
+
+	payload, err := json.Marshal(presence)
+	if err != nil {
+		fmt.Printf("failed to encode presence: %v\n", err)
+		return
+	}
+
+	msg, err := NewEnvelope(MsgPresence, 0, payload).MarshalBinary()
+	if err != nil {
+		fmt.Printf("failed to encode presence: %v\n", err)
+		return
+	}
+
+	for _, client := range h.clients {
+		h.sendTo(client, msg)
+	}
+}
+
+// queue an encoded envelope for a client, disconnecting it when it cant keep up. Caller must hold h.mu
+func (h *Hub) sendTo(client *roomClient, msg []byte) {
+	if !client.enqueue(msg) {
+		fmt.Printf("disconnecting client %d, it fell %d messages behind\n", client.participant.ID, sendQueueSize)
+		client.stop()
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHubAdmit(t *testing.T) {
+	h := NewHub(nil)
+	h.clients[nil] = &roomClient{participant: Participant{ID: 7}, rejoin: "s3cret"}
+
+	tests := []struct {
+		name   string
+		id     uint32
+		rejoin string
+		want   error
+	}{
+		{"new id", 8, "", nil},
+		{"new id with a stray secret", 8, "whatever", nil},
+		{"taken id without secret", 7, "", ErrIDTaken},
+		{"taken id with the wrong secret", 7, "guess", ErrIDTaken},
+		{"reconnect with the secret", 7, "s3cret", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := h.Admit(tt.id, tt.rejoin); !errors.Is(err, tt.want) {
+				t.Errorf("Admit(%d, %q) = %v, want %v", tt.id, tt.rejoin, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubAdmitWithoutSecret(t *testing.T) {
+	// a connection that was never given a secret cant be replaced at all
+	h := NewHub(nil)
+	h.clients[nil] = &roomClient{participant: Participant{ID: 7}}
+
+	if err := h.Admit(7, ""); !errors.Is(err, ErrIDTaken) {
+		t.Errorf("Admit() = %v, want %v", err, ErrIDTaken)
+	}
+}
+
+func TestHubAdmitClosed(t *testing.T) {
+	h := NewHub(nil)
+	h.Close("done")
+
+	if err := h.Admit(1, ""); !errors.Is(err, ErrHubClosed) {
+		t.Errorf("Admit() = %v, want %v", err, ErrHubClosed)
+	}
+}
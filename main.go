@@ -1,13 +1,17 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand/v2"
+	"net"
 	"net/http"
-	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,15 +32,14 @@ const (
 	AppStateRoomSelect          // after selecting to join room, select from list of available rooms
 	AppStateDrawStart           // showing 'Draw Here...' text before anything is drawn
 	AppStateDrawing             // when the user is actively drawing
+	AppStateRoomSetup           // after selecting to make a room, name and describe it
+	AppStateNickname            // before the room options, pick the name others see
 )
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
-var clients = make(map[*websocket.Conn]bool)
-var clientsMu sync.Mutex
-
 type FontSet struct {
 	Regular    rl.Font
 	Bold       rl.Font
@@ -64,14 +67,49 @@ type TwentyRadiusCircle struct {
 
 type Room struct {
 	hostName string
+	Name     string // display name advertised by the host
 	Addr     string
 	Port     int
+	Path     string // path of the room on the host, one host can serve several rooms
 	URL      string
+
+	Topic        string // what the room is about, may be empty
+	Tag          string // optional short label, shown as #tag
+	Proto        int    // protocol version spoken by the host, 0 when not advertised
+	Participants int    // people in the room when it was last advertised
+	Locked       bool   // a password is needed to join
+}
+
+// details line shown under the room name in the room picker
+func (r Room) Details() string {
+	var parts []string
+	if r.Topic != "" {
+		parts = append(parts, r.Topic)
+	}
+	if r.Tag != "" {
+		parts = append(parts, "#"+r.Tag)
+	}
+	if r.Locked {
+		parts = append(parts, "locked")
+	}
+	parts = append(parts, fmt.Sprintf("%d in room", r.Participants))
+
+	if !r.Compatible() {
+		parts = append(parts, fmt.Sprintf("needs v%d", r.Proto))
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+// false when the host advertises a protocol version we cant speak. Hosts that dont advertise one are given a try
+func (r Room) Compatible() bool {
+	return r.Proto == 0 || r.Proto == int(ProtocolVersion)
 }
 
 type App struct {
 	currentAppState AppState // app state to handle events
 	font            FontSet  // store fonts
+	config          Config   // command line settings
 
 	mouseX float32 // store x coordinates of mouse position
 	mouseY float32 // store y coordinates of mouse position
@@ -82,24 +120,74 @@ type App struct {
 	joinRoomButton      rl.Rectangle
 	joinRoomButtonColor rl.Color
 
-	server         *http.Server
-	MDNSServer     *mdns.Server
+	roomForm     *Form // name, topic and tag of the room being made
+	nicknameForm *Form // nickname prompt shown before the room options
+	passwordForm *Form // password prompt for a locked room, nil when closed
+	pendingRoom  Room  // locked room waiting for its password
+	addressForm  *Form // 'Join by address' field on the room select screen
+
+	roomServer     *RoomServer // serves the rooms hosted by this process, nil until the first one is made
+	hostedRoom     *hostedRoom // room made from this window, nil when not hosting
 	isServerBooted bool
 
-	availRooms    []Room // slice for deterministic order
+	availRooms    []Room   // slice for deterministic order
+	manualRooms   []Room   // rooms of the host last joined by address
+	autoJoin      *Room    // room found by address that should be joined on the next frame
+	recentServers []string // addresses joined by hand, most recent first
 	currentRoom   Room
 	lastMDNSQuery time.Time
 
 	ws         *websocket.Conn
+	leaveRoom  context.CancelFunc // stops the connection supervisor, nil when not in a room
+	joining    bool               // true while JoinWsServer dials a room, other joins are ignored until it is done
 	isRoomHost bool
-
-	drawnPixels       []rl.Vector2 // store all drawn 'circles' on the screen (not necessarily pixels)
-	currentDrawRadius float32      // radius of the cirlces drawn
+	hostToken  string // secret our window presents to the room it hosts, ids are public so only this proves it is the host
+	joinErr    string // reason the last attempt to join or make a room failed, shown to the user
+
+	openCanvasPath string // saved canvas loaded into the next room we host, from -open or dropped on the menu
+
+	notice      string    // short message about the last export/save, shown on top of the canvas
+	noticeUntil time.Time // when the notice disappears
+	roomRules   Welcome   // rules of the current room, received from the host when joining
+
+	reconnecting     bool      // true while the room connection is down and being redialed
+	reconnectAttempt int       // redial attempts since the connection dropped
+	nextReconnect    time.Time // when the next redial happens
+	roomLost         string    // why the supervisor gave up on the room, handled on the next frame
+	roomEnded        string    // reason the host gave for ending the room, shown in a prompt until dismissed
+	takeover         *Handoff  // room we should host now that its host is gone, handled on the next frame
+	endedRoomSave    string    // where the canvas of the ended room was saved from the prompt
+
+	participants []Participant // everyone in the room, in the order they joined, as last sent by the host
+
+	confirmingClear bool // true while the 'clear for everyone?' prompt is open
+
+	canvas            *Canvas    // every stroke drawn in the room, merged from local and remote deltas
+	outbox            []outgoing // local deltas and messages waiting for the sender
+	clientID          uint32     // random id that keeps our stroke ids distinct from other clients
+	nickname          string     // name shown to everyone in the room, sent in our hello
+	nextStrokeID      uint32     // id given to the next stroke started by this client
+	isStroking        bool       // true while the mouse is held down and points are added to the current stroke
+	strokeSeq         uint32     // number of points already added to the current stroke
+	strokeTool        Tool       // tool of the current stroke, captured when it started
+	strokeRadius      float32    // radius of the current stroke, captured when it started
+	strokeColor       rl.Color   // color of the current stroke, captured when it started
+	strokeTime        int64      // unix milliseconds when the current stroke started
+	undoStack         []uint32   // ids of our own strokes that can be undone, most recent last
+	redoStack         []uint32   // ids of our own undone strokes that can be redone, most recently undone last
+	currentDrawRadius float32    // radius of the cirlces drawn
+	currentDrawColor  rl.Color   // color of the cirlces drawn
+	currentTool       Tool       // pen or eraser, the eraser uses currentDrawRadius as its size
 
 	fiveC   FiveRadiusCircle
 	tenC    TenRadiusCircle
 	twentyC TwentyRadiusCircle
 
+	paletteIndex    int      // selected swatch, len(paletteColors) is the custom color
+	customColor     rl.Color // color mixed with the RGB sliders
+	showColorPicker bool     // true while the RGB picker popup is open
+	activeSlider    int      // RGB slider being dragged, noSlider when none
+
 	mu sync.RWMutex
 
 	lastDrawnPixel rl.Vector2 // storing last drawn pixel will help interpolation to smooth drawing lines
@@ -108,8 +196,15 @@ type App struct {
 func (a *App) Init() {
 	a.currentAppState = AppStateStart
 
-	// set default circle radius to 10
+	a.canvas = NewCanvas()
+	a.clientID = rand.Uint32()
+	a.openCanvasPath = a.config.OpenCanvas
+
+	// set default circle radius to 10 and draw in white
 	a.currentDrawRadius = 10
+	a.currentDrawColor = rl.White
+	a.customColor = rl.Pink
+	a.activeSlider = noSlider
 
 	cps := codePoints()
 
@@ -166,94 +261,111 @@ func (a *App) Draw() {
 		makeRoomText := "Make Room"
 		rl.DrawTextEx(a.font.BoldItalic, makeRoomText, rl.NewVector2(a.makeRoomButton.X+float32(12), a.makeRoomButton.Y+float32(25)), 40, 3, rl.White)
 
+		if a.joinErr != "" {
+			drawTextCentered(a.font.Italic, a.joinErr, screenHeight-100, 25, rl.Red)
+		}
+
+		// saved canvas that will be shown to everyone in the room we make
+		if a.openCanvasPath != "" {
+			drawTextCentered(a.font.Italic, fmt.Sprintf("Your room will open %s", filepath.Base(a.openCanvasPath)), (screenHeight/2)+150, 25, rl.Gray)
+		} else {
+			drawTextCentered(a.font.Italic, "Drop a .picto file here to open it in your room", (screenHeight/2)+150, 25, rl.DarkGray)
+		}
+		a.DrawNotice()
+
+	case AppStateRoomSetup:
+		a.DrawRoomSetup()
+
+	case AppStateNickname:
+		a.DrawNickname()
+
 	case AppStateRoomSelect:
 		t1 := "Select a room..."
-		drawTextCentered(a.font.Regular, t1, (screenHeight/2)-250, 50, rl.White)
+		drawTextCentered(a.font.Regular, t1, (screenHeight/2)-320, 50, rl.White)
 
-		if len(a.availRooms) != 0 {
+		if rooms := a.roomList(); len(rooms) != 0 {
 			var gap = 50
-			for i, room := range a.availRooms {
-				// dont think there would be a situation where more than 5 rooms would be made. For now, will skip over them in the UI but in the future can add a scrolling section
-				if i > 5 {
+			for i, room := range rooms {
+				// dont think there would be a situation where more than 4 rooms would be made. For now, will skip over them in the UI but in the future can add a scrolling section
+				if i > 3 {
 					continue
 				}
 
-				insertRec := rl.NewRectangle(((screenWidth / 2) - (350 / 2)), float32((screenHeight/2)+((i*100)+gap)-200), float32(350), float32(70))
+				insertRec := rl.NewRectangle(((screenWidth / 2) - (500 / 2)), float32((screenHeight/2)+((i*100)+gap)-240), float32(500), float32(90))
 				roomContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
 
-				var hostName string
-				hostRunes := []rune(room.hostName)
-				if len(hostRunes) > 15 {
-					hostName = fmt.Sprintf("%s...", string(hostRunes[:16]))
+				var roomName string
+				nameRunes := []rune(room.Name)
+				if len(nameRunes) > 24 {
+					roomName = fmt.Sprintf("%s...", string(nameRunes[:24]))
 				} else {
-					hostName = string(hostRunes)
+					roomName = string(nameRunes)
 				}
 
-				hostNameMes := rl.MeasureTextEx(a.font.Italic, hostName, 35, 2)
+				roomNameMes := rl.MeasureTextEx(a.font.Italic, roomName, 35, 2)
 
-				if rl.CheckCollisionPointRec(rl.NewVector2(a.mouseX, a.mouseY), insertRec) {
+				// the list is inactive while the password prompt is open on top of it
+				if a.passwordForm == nil && rl.CheckCollisionPointRec(rl.NewVector2(a.mouseX, a.mouseY), insertRec) {
 					rl.DrawRectangleRounded(insertRec, float32(0.5), int32(0), rl.Blue)
 					if rl.IsMouseButtonReleased(rl.MouseButtonLeft) {
-						go a.JoinWsServer(room.URL)
-						a.currentRoom = room
+						a.JoinRoom(room)
 					}
 				} else {
 					rl.DrawRectangleRounded(insertRec, float32(0.5), int32(0), rl.White)
 				}
 				rl.DrawRectangleRounded(roomContainer, float32(0.5), int32(0), rl.Black)
-				rl.DrawTextEx(a.font.Italic, hostName, rl.NewVector2((insertRec.X+(insertRec.Width/2))-(hostNameMes.X/2), insertRec.Y+(insertRec.Height/2)-(35/2)), 35, 2, rl.White)
+				rl.DrawTextEx(a.font.Italic, roomName, rl.NewVector2((insertRec.X+(insertRec.Width/2))-(roomNameMes.X/2), insertRec.Y+10), 35, 2, rl.White)
+
+				// topic, tag and head count in a smaller line, red when we cant talk to the host
+				details := room.Details()
+				detailsRunes := []rune(details)
+				if len(detailsRunes) > 40 {
+					details = fmt.Sprintf("%s...", string(detailsRunes[:40]))
+				}
+				detailsColor := rl.Gray
+				if !room.Compatible() {
+					detailsColor = rl.Red
+				}
+				detailsMes := rl.MeasureTextEx(a.font.Italic, details, 22, 1)
+				rl.DrawTextEx(a.font.Italic, details, rl.NewVector2((insertRec.X+(insertRec.Width/2))-(detailsMes.X/2), insertRec.Y+52), 22, 1, detailsColor)
 			}
 		} else {
-			drawTextCentered(a.font.Italic, "No rooms found :(", screenHeight/2, 35, rl.White)
+			drawTextCentered(a.font.Italic, "No rooms found :(", (screenHeight/2)-100, 35, rl.White)
 		}
 
-	// essentially the same as drawing but shows 'Draw Here...' prompt
-	case AppStateDrawStart:
-		t1 := "Draw Here..."
-		drawTextCentered(a.font.Italic, t1, (screenHeight/2)-40, 35, rl.White)
+		a.DrawAddressInput()
 
-		// check if the user is the host of the room
-		var hostLabel string
-		if a.isRoomHost {
-			hostLabel = "Host: You"
-		} else {
-			hostLabel = fmt.Sprintf("Host: %s", a.currentRoom.hostName)
+		// explain why the last join failed (unreachable host, incompatible version...)
+		if a.joinErr != "" {
+			drawTextCentered(a.font.Italic, a.joinErr, screenHeight-45, 25, rl.Red)
 		}
 
-		// draw the host label to identify who is the host
-		rl.DrawTextEx(a.font.Italic, hostLabel, rl.NewVector2((screenWidth-500), 10), 35, 3, rl.Red)
-
-		if a.isRoomHost {
-			// draw the number of connected clients
-			clientsMu.Lock()
-			clientsLabel := fmt.Sprintf("Clients: %d", len(clients))
-			clientsMu.Unlock()
-
-			rl.DrawTextEx(a.font.Italic, clientsLabel, rl.NewVector2((screenWidth-500), 50), 35, 2, rl.Red)
+		if a.passwordForm != nil {
+			a.DrawPasswordPrompt()
 		}
 
-		// draw mouse pos and label
-		mousePos := fmt.Sprintf("(%.0f, %.0f)", a.mouseX, a.mouseY)
-		rl.DrawTextEx(a.font.Italic, "Mouse Pos.", rl.NewVector2(50, 10), 35, 3, rl.White)
-		rl.DrawTextEx(a.font.Italic, mousePos, rl.NewVector2(40, 50), 35, 2, rl.White)
+	// essentially the same as drawing but shows 'Draw Here...' prompt
+	case AppStateDrawStart:
+		// a late joiner already has the room's drawing to look at, only prompt on an empty canvas
+		a.DrawCanvas()
 
-		// draw menu shortcut
-		rl.DrawTextEx(a.font.Italic, "Menu", rl.NewVector2(300, 10), 35, 2, rl.White)
-		rl.DrawTextEx(a.font.Italic, "[M]", rl.NewVector2(305, 50), 35, 2, rl.White)
+		a.mu.RLock()
+		isEmpty := a.canvas.IsEmpty()
+		a.mu.RUnlock()
+
+		if isEmpty {
+			t1 := "Draw Here..."
+			drawTextCentered(a.font.Italic, t1, (screenHeight/2)-40, 35, rl.White)
+		}
 
-		// draw space shortcut
-		rl.DrawTextEx(a.font.Italic, "Clear", rl.NewVector2(460, 10), 35, 2, rl.White)
-		rl.DrawTextEx(a.font.Italic, "[Space]", rl.NewVector2(440, 50), 35, 2, rl.White)
+		a.DrawRoomHUD()
+		a.DrawConnectionBanner()
+		a.DrawParticipantList()
 
-		// draw 'Drawing Tools' section
-		insertRec := rl.NewRectangle(float32(40), float32(screenHeight)-150, float32(350), float32(100))
+		// place the radii selection tools inside the 'Drawing Tools' section
+		insertRec := drawingToolsRect()
 		radiusContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
 
-		rl.DrawTextEx(a.font.Italic, "Drawing Tools", rl.NewVector2(insertRec.X+70, insertRec.Y-40), 35, 2, rl.White)
-		rl.DrawRectangleRounded(insertRec, float32(0.5), int32(0), rl.White)
-		rl.DrawRectangleRounded(radiusContainer, float32(0.5), int32(0), rl.Black)
-
-		// add radii selection tools
 		conX := radiusContainer.ToInt32().X
 		conY := radiusContainer.ToInt32().Y
 		conH := radiusContainer.ToInt32().Height
@@ -275,64 +387,121 @@ func (a *App) Draw() {
 			a.twentyC = TwentyRadiusCircle{X: conX + 250 + 23, Y: conY + (conH / 2), Radius: float32(20), Color: rl.Blue}
 		}
 
-		rl.DrawCircle(a.fiveC.X, a.fiveC.Y, a.fiveC.Radius, a.fiveC.Color)
-		rl.DrawCircle(a.tenC.X, a.tenC.Y, a.tenC.Radius, a.tenC.Color)
-		rl.DrawCircle(a.twentyC.X, a.twentyC.Y, a.twentyC.Radius, a.twentyC.Color)
+		a.DrawTools()
+
+		if a.endedRoom() != "" {
+			a.DrawRoomEnded()
+		}
 
 	// actively drawing state, drop prompt and and draw the circles
 	case AppStateDrawing:
-		a.mu.RLock()
-		for _, p := range a.drawnPixels {
-			rl.DrawCircle(int32(p.X), int32(p.Y), a.currentDrawRadius, rl.White)
+		a.DrawCanvas()
+		a.DrawRoomHUD()
+		a.DrawConnectionBanner()
+		a.DrawParticipantList()
+		a.DrawTools()
+
+		// outline the eraser under the cursor, since erasing paints with the background it would be invisible otherwise
+		if a.currentTool == ToolEraser && !a.isOverTools() {
+			rl.DrawCircleLines(int32(a.mouseX), int32(a.mouseY), a.currentDrawRadius, rl.Gray)
 		}
-		a.mu.RUnlock()
 
-		// check if the user is the host of the room
-		var hostLabel string
-		if a.isRoomHost {
-			hostLabel = "Host: You"
-		} else {
-			hostLabel = fmt.Sprintf("Host: %s", a.currentRoom.hostName)
+		a.DrawShortcutLegend()
+		a.DrawNotice()
+
+		if a.confirmingClear {
+			a.DrawConfirmClear()
 		}
 
-		// draw the host label to identify who is the host
-		rl.DrawTextEx(a.font.Italic, hostLabel, rl.NewVector2((screenWidth-500), 10), 35, 3, rl.Red)
+		if a.endedRoom() != "" {
+			a.DrawRoomEnded()
+		}
+	}
+}
 
-		if a.isRoomHost {
-			// draw the number of connected clients
-			clientsMu.Lock()
-			clientsLabel := fmt.Sprintf("Clients: %d", len(clients))
-			clientsMu.Unlock()
+// draw every stroke that hasnt been undone, in the order they were started
+func (a *App) DrawCanvas() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-			rl.DrawTextEx(a.font.Italic, clientsLabel, rl.NewVector2((screenWidth-500), 50), 35, 2, rl.Red)
+	drawStrokes(a.canvas.Strokes())
+}
+
+// draw the labels and shortcuts shown on top of the canvas while in a room
+func (a *App) DrawRoomHUD() {
+	// check if the user is the host of the room
+	var hostLabel string
+	if a.isRoomHost {
+		hostLabel = "Host: You"
+	} else {
+		// the nickname of the host when it is in the participant list, its machine otherwise
+		a.mu.RLock()
+		hostLabel = fmt.Sprintf("Host: %s", a.currentRoom.hostName)
+		if host, ok := findParticipant(a.participants, a.roomRules.HostID); ok && a.roomRules.HostID != 0 {
+			hostLabel = fmt.Sprintf("Host: %s", participantName(host))
 		}
+		a.mu.RUnlock()
+	}
+
+	// draw the host label to identify who is the host
+	rl.DrawTextEx(a.font.Italic, hostLabel, rl.NewVector2((screenWidth-500), 10), 35, 3, rl.Red)
 
-		// draw mouse pos and label
-		mousePos := fmt.Sprintf("(%.0f, %.0f)", a.mouseX, a.mouseY)
-		rl.DrawTextEx(a.font.Italic, "Mouse Pos.", rl.NewVector2(50, 10), 35, 3, rl.White)
-		rl.DrawTextEx(a.font.Italic, mousePos, rl.NewVector2(40, 50), 35, 2, rl.White)
+	// draw the number of connected clients, the host counts its own connections and everyone else uses the
+	// participant list
+	clients := 0
+	if a.isRoomHost && a.hostedRoom != nil {
+		clients = a.hostedRoom.ClientCount()
+	} else {
+		a.mu.RLock()
+		clients = len(a.participants)
+		a.mu.RUnlock()
+	}
 
-		// draw menu shortcut
-		rl.DrawTextEx(a.font.Italic, "Menu", rl.NewVector2(300, 10), 35, 2, rl.White)
-		rl.DrawTextEx(a.font.Italic, "[M]", rl.NewVector2(305, 50), 35, 2, rl.White)
+	if clients > 0 {
+		clientsLabel := fmt.Sprintf("Clients: %d", clients)
 
-		// draw space shortcut
-		rl.DrawTextEx(a.font.Italic, "Clear", rl.NewVector2(460, 10), 35, 2, rl.White)
-		rl.DrawTextEx(a.font.Italic, "[Space]", rl.NewVector2(440, 50), 35, 2, rl.White)
+		rl.DrawTextEx(a.font.Italic, clientsLabel, rl.NewVector2((screenWidth-500), 50), 35, 2, rl.Red)
+	}
 
-		// draw 'Drawing Tools' section
-		insertRec := rl.NewRectangle(float32(40), float32(screenHeight)-150, float32(350), float32(100))
-		radiusContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
+	// draw mouse pos and label
+	mousePos := fmt.Sprintf("(%.0f, %.0f)", a.mouseX, a.mouseY)
+	rl.DrawTextEx(a.font.Italic, "Mouse Pos.", rl.NewVector2(50, 10), 35, 3, rl.White)
+	rl.DrawTextEx(a.font.Italic, mousePos, rl.NewVector2(40, 50), 35, 2, rl.White)
 
-		rl.DrawTextEx(a.font.Italic, "Drawing Tools", rl.NewVector2(insertRec.X+70, insertRec.Y-40), 35, 2, rl.White)
-		rl.DrawRectangleRounded(insertRec, float32(0.5), int32(0), rl.White)
-		rl.DrawRectangleRounded(radiusContainer, float32(0.5), int32(0), rl.Black)
+	// draw menu shortcut
+	rl.DrawTextEx(a.font.Italic, "Menu", rl.NewVector2(300, 10), 35, 2, rl.White)
+	rl.DrawTextEx(a.font.Italic, "[M]", rl.NewVector2(305, 50), 35, 2, rl.White)
 
-		// add radii selection tools
-		rl.DrawCircle(a.fiveC.X, a.fiveC.Y, a.fiveC.Radius, a.fiveC.Color)
-		rl.DrawCircle(a.tenC.X, a.tenC.Y, a.tenC.Radius, a.tenC.Color)
-		rl.DrawCircle(a.twentyC.X, a.twentyC.Y, a.twentyC.Radius, a.twentyC.Color)
+	// draw space shortcut, greyed out when only the host may clear
+	clearColor := rl.White
+	if !a.canClear() {
+		clearColor = rl.DarkGray
 	}
+	rl.DrawTextEx(a.font.Italic, "Clear", rl.NewVector2(460, 10), 35, 2, clearColor)
+	rl.DrawTextEx(a.font.Italic, "[Space]", rl.NewVector2(440, 50), 35, 2, clearColor)
+
+	// draw undo shortcut (shift for redo)
+	rl.DrawTextEx(a.font.Italic, "Undo", rl.NewVector2(665, 10), 35, 2, rl.White)
+	rl.DrawTextEx(a.font.Italic, "[Ctrl+Z]", rl.NewVector2(630, 50), 35, 2, rl.White)
+}
+
+// draw the 'Drawing Tools' and 'Colors' sections
+func (a *App) DrawTools() {
+	insertRec := drawingToolsRect()
+	radiusContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
+
+	rl.DrawTextEx(a.font.Italic, "Drawing Tools", rl.NewVector2(insertRec.X+120, insertRec.Y-40), 35, 2, rl.White)
+	rl.DrawRectangleRounded(insertRec, float32(0.5), int32(0), rl.White)
+	rl.DrawRectangleRounded(radiusContainer, float32(0.5), int32(0), rl.Black)
+
+	// add radii selection tools
+	rl.DrawCircle(a.fiveC.X, a.fiveC.Y, a.fiveC.Radius, a.fiveC.Color)
+	rl.DrawCircle(a.tenC.X, a.tenC.Y, a.tenC.Radius, a.tenC.Color)
+	rl.DrawCircle(a.twentyC.X, a.twentyC.Y, a.twentyC.Radius, a.twentyC.Color)
+	a.DrawEraser()
+
+	// draw 'Colors' section next to the radii
+	a.DrawPalette()
 }
 
 func (a *App) Update() {
@@ -340,18 +509,12 @@ func (a *App) Update() {
 	// start screen, on space press will enter application, make sure drawn pixels are empty or are reset once visiting menu
 	case AppStateStart:
 		a.OnSpacePressed()
-
-		a.mu.Lock()
-		a.drawnPixels = []rl.Vector2{}
-		a.mu.Unlock()
+		a.ResetCanvas()
 
 	case AppStateRoomConfig:
 		a.GetMousePos()
 		a.OnMPressed()
-
-		if a.isRoomHost && a.isServerBooted {
-			a.currentAppState = AppStateDrawStart
-		}
+		a.OnFileDropped()
 
 		// change button color is mouse position is inside button and handle click events for both buttons using IsMouseButtonReleased
 		if rl.CheckCollisionPointRec(rl.NewVector2(a.mouseX, a.mouseY), a.joinRoomButton) {
@@ -359,12 +522,7 @@ func (a *App) Update() {
 
 			// handle click events on 'Join Room' button -> room selection screen after mDNS query
 			if rl.IsMouseButtonReleased(rl.MouseButtonLeft) {
-				a.isRoomHost = false
-				go func() {
-					a.MDNSLookup()
-					a.lastMDNSQuery = time.Now()
-				}()
-				a.currentAppState = AppStateRoomSelect
+				a.OpenRoomSelect()
 			}
 		} else {
 			a.joinRoomButtonColor = rl.White // change button color back to white when no collision
@@ -374,25 +532,37 @@ func (a *App) Update() {
 		if rl.CheckCollisionPointRec(rl.NewVector2(a.mouseX, a.mouseY), a.makeRoomButton) {
 			a.makeRoomButtonColor = rl.Blue // change button color to blue on hover
 
-			// handle click events on 'Make Room' button
+			// handle click events on 'Make Room' button -> name and describe the room before making it
 			if rl.IsMouseButtonReleased(rl.MouseButtonLeft) {
-				a.isRoomHost = true
-				go func() {
-					a.StartMDNS()
-				}()
-				go func() {
-					a.JoinWsServer("ws://0.0.0.0:8000/ws")
-				}()
+				a.joinErr = ""
+				a.roomForm = newRoomSetupForm()
+				a.currentAppState = AppStateRoomSetup
 			}
 		} else {
 			a.makeRoomButtonColor = rl.White // change button color back to white when no collision
 		}
 
+	case AppStateRoomSetup:
+		a.UpdateRoomSetup()
+
+	case AppStateNickname:
+		a.UpdateNickname()
+
 	case AppStateRoomSelect:
 		a.GetMousePos()
-		a.OnMPressed()
 
-		if a.currentRoom.URL != "" {
+		// the prompt takes the keyboard, [M] is typed into the password instead of leaving
+		if a.passwordForm != nil {
+			a.UpdatePasswordPrompt()
+		} else {
+			a.UpdateAddressInput()
+			if a.addressForm.Focused() == nil {
+				a.OnMPressed()
+			}
+		}
+
+		// only enter the room once the host has accepted our hello
+		if a.currentRoom.URL != "" && a.isServerBooted {
 			a.currentAppState = AppStateDrawStart
 		}
 
@@ -403,17 +573,45 @@ func (a *App) Update() {
 
 	// draw start just to show the draw prompt but there is no handler for clearing drawing
 	case AppStateDrawStart:
+		// once the host ended the room only the prompt is handled
+		if a.endedRoom() != "" {
+			a.UpdateRoomEnded()
+			break
+		}
+
+		a.UpdateConnection()
 		a.OnMPressed()
+		a.OnHandOffPressed()
 		a.GetMousePos()
+		a.UpdateEraser()
+		a.UpdatePalette()
 		a.OnMousePress()
 
 	// user is actively drawing and has access to shortcut controls
 	case AppStateDrawing:
+		if a.endedRoom() != "" {
+			a.UpdateRoomEnded()
+			break
+		}
+
+		// while the clear prompt is open only its answers are handled, so a stray click doesnt draw under it
+		if a.confirmingClear {
+			a.OnClearConfirm()
+			break
+		}
+
+		a.UpdateConnection()
 		a.OnSpacePressed()
+		a.OnUndoPressed()
+		a.OnExportPressed()
+		a.OnSaveOpenPressed()
+		a.OnFileDropped()
 		a.OnMPressed()
+		a.OnHandOffPressed()
 		a.GetMousePos()
+		a.UpdateEraser()
+		a.UpdatePalette()
 		a.OnMousePress()
-		a.SendDrawingsToWs()
 
 		// handle drawing tool hover and click. on click, change the currentDrawRadius
 		// handle conditions for 5 radius cirlce
@@ -448,21 +646,120 @@ func (a *App) Update() {
 	}
 }
 
+// ResetCanvas forgets the drawing of the last room, along with anything still waiting to be sent
+func (a *App) ResetCanvas() {
+	a.mu.Lock()
+	a.canvas.Clear()
+	a.outbox = nil
+	a.undoStack = nil
+	a.redoStack = nil
+	a.mu.Unlock()
+}
+
 // depending on state handle space press
 func (a *App) OnSpacePressed() {
 	switch a.currentAppState {
 	case AppStateStart:
 		if rl.IsKeyPressed(rl.KeySpace) {
-			a.currentAppState = AppStateRoomConfig
+			a.OpenNicknamePrompt()
 		}
 
+	// ask before clearing, the canvas is shared with the whole room
 	case AppStateDrawing:
-		if rl.IsKeyPressed(rl.KeySpace) {
-			a.mu.Lock()
-			a.drawnPixels = nil
-			a.mu.Unlock()
+		if rl.IsKeyPressed(rl.KeySpace) && a.canClear() {
+			a.confirmingClear = true
+		}
+	}
+}
+
+// Ctrl+Z undoes our last stroke, Ctrl+Shift+Z redoes it (Cmd works too on macOS). Other people's strokes are never touched
+func (a *App) OnUndoPressed() {
+	if !rl.IsKeyPressed(rl.KeyZ) {
+		return
+	}
+
+	if !isCtrlDown() {
+		return
+	}
+
+	if isShiftDown() {
+		a.Redo()
+	} else {
+		a.Undo()
+	}
+}
+
+// hide our most recent visible stroke for the whole room
+func (a *App) Undo() {
+	a.mu.Lock()
+
+	// skip over strokes that no longer exist, e.g. because the canvas was cleared since
+	var id uint32
+	found := false
+	for len(a.undoStack) > 0 && !found {
+		id = a.undoStack[len(a.undoStack)-1]
+		a.undoStack = a.undoStack[:len(a.undoStack)-1]
+		found = a.canvas.SetUndone(a.clientID, id, true)
+	}
+
+	if found {
+		a.redoStack = append(a.redoStack, id)
+	}
+	a.mu.Unlock()
+
+	if found {
+		a.SendStrokeRefToWs(MsgUndo, id)
+	}
+}
+
+// keep the ids of our own strokes that are on the canvas, in order. Caller must hold a.mu
+func (a *App) knownStrokes(ids []uint32) []uint32 {
+	var known []uint32
+	for _, id := range ids {
+		if a.canvas.Has(a.clientID, id) {
+			known = append(known, id)
 		}
 	}
+	return known
+}
+
+// show our most recently undone stroke again for the whole room
+func (a *App) Redo() {
+	a.mu.Lock()
+
+	var id uint32
+	found := false
+	for len(a.redoStack) > 0 && !found {
+		id = a.redoStack[len(a.redoStack)-1]
+		a.redoStack = a.redoStack[:len(a.redoStack)-1]
+		found = a.canvas.SetUndone(a.clientID, id, false)
+	}
+
+	if found {
+		a.undoStack = append(a.undoStack, id)
+	}
+	a.mu.Unlock()
+
+	if found {
+		a.SendStrokeRefToWs(MsgRedo, id)
+	}
+}
+
+// answer the clear prompt with [Y] or dismiss it with [N]
+func (a *App) OnClearConfirm() {
+	if rl.IsKeyPressed(rl.KeyY) {
+		a.confirmingClear = false
+		a.SendClearToWs()
+	}
+
+	if rl.IsKeyPressed(rl.KeyN) {
+		a.confirmingClear = false
+	}
+}
+
+// some rooms only let the host clear the canvas
+func (a *App) canClear() bool {
+	return a.isRoomHost || !a.roomRules.HostOnlyClear
 }
 
 // shortcut to navigate back to menu on 'M' press
@@ -475,26 +772,17 @@ func (a *App) OnMPressed() {
 
 	case AppStateRoomSelect:
 		if rl.IsKeyPressed(rl.KeyM) {
+			a.LeaveRoom() // stop a join still dialing
 			a.currentAppState = AppStateStart
 			a.availRooms = []Room{}
+			a.manualRooms = nil
 		}
 
 	case AppStateDrawStart:
 		if rl.IsKeyPressed(rl.KeyM) {
 			if a.isServerBooted && a.isRoomHost {
-				ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-				defer cancel()
-
-				a.server.Shutdown(ctx)
-				fmt.Println("Server Shutdown...")
-
-				a.MDNSServer.Shutdown()
-				fmt.Println("MDNS Server Shutdown...")
-
-				clientsMu.Lock()
-				clients = make(map[*websocket.Conn]bool)
-				clientsMu.Unlock()
-
+				a.LeaveRoom()
+				a.CloseHostedRoom()
 				a.currentAppState = AppStateStart
 			}
 		}
@@ -502,47 +790,74 @@ func (a *App) OnMPressed() {
 	case AppStateDrawing:
 		if rl.IsKeyReleased(rl.KeyM) {
 			if a.isServerBooted && a.isRoomHost {
-				ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-				defer cancel()
-
-				a.server.Shutdown(ctx)
-				fmt.Println("Server Shutdown...")
-
-				a.MDNSServer.Shutdown()
-				fmt.Println("MDNS Server Shutdown...")
-
-				clientsMu.Lock()
-				clients = make(map[*websocket.Conn]bool)
-				clientsMu.Unlock()
-
-				a.mu.Lock()
-				a.currentRoom = Room{}
-				a.mu.Unlock()
-
+				a.LeaveRoom()
+				a.CloseHostedRoom()
 				a.currentAppState = AppStateStart
 			}
 			if a.isServerBooted && !a.isRoomHost {
-				fmt.Println("Closing server connection...")
-				a.ws.Close()
-				a.currentRoom = Room{}
-				a.isServerBooted = false
+				a.LeaveRoom()
 				a.currentAppState = AppStateStart
 			}
 		}
 	}
 }
 
+// shortcut for the host to leave the room on 'H' press without ending it, someone still in it takes over
+func (a *App) OnHandOffPressed() {
+	if !rl.IsKeyPressed(rl.KeyH) || !a.isServerBooted || !a.isRoomHost {
+		return
+	}
+
+	a.LeaveRoom()
+	a.HandOffHostedRoom()
+	a.currentAppState = AppStateStart
+}
+
+// draw the prompt asking to confirm a room wide clear on top of the canvas
+func (a *App) DrawConfirmClear() {
+	insertRec := rl.NewRectangle((screenWidth/2)-350, (screenHeight/2)-100, float32(700), float32(200))
+	promptContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
+
+	rl.DrawRectangleRounded(insertRec, float32(0.3), int32(0), rl.White)
+	rl.DrawRectangleRounded(promptContainer, float32(0.3), int32(0), rl.Black)
+
+	drawTextCentered(a.font.Regular, "Clear the canvas for everyone?", int(insertRec.Y)+45, 40, rl.White)
+	drawTextCentered(a.font.Italic, "[Y] Yes     [N] No", int(insertRec.Y)+115, 35, rl.White)
+}
+
 // handle mouse button presses (left button)
 func (a *App) OnMousePress() {
 	switch a.currentAppState {
 	case AppStateDrawStart:
-		if rl.IsMouseButtonDown(rl.MouseButtonLeft) {
+		if rl.IsMouseButtonDown(rl.MouseButtonLeft) && !a.isOverTools() {
 			a.currentAppState = AppStateDrawing
 		} else {
 			a.lastDrawnPixel = rl.NewVector2(a.mouseX, a.mouseY)
 		}
 	case AppStateDrawing:
 		if rl.IsMouseButtonDown(rl.MouseButtonLeft) {
+			// start a new stroke on the first frame the button is held, unless a tool is being clicked
+			if !a.isStroking {
+				if a.isOverTools() || a.activeSlider != noSlider {
+					a.lastDrawnPixel = rl.NewVector2(a.mouseX, a.mouseY)
+					return
+				}
+
+				a.isStroking = true
+				a.nextStrokeID++
+				a.strokeSeq = 0
+				a.strokeTool = a.currentTool
+				a.strokeRadius = a.currentDrawRadius
+				a.strokeColor = a.currentDrawColor
+				a.strokeTime = time.Now().UnixMilli()
+
+				// a new stroke starts a new branch of history, whatever was undone can no longer be redone
+				a.mu.Lock()
+				a.undoStack = append(a.undoStack, a.nextStrokeID)
+				a.redoStack = nil
+				a.mu.Unlock()
+			}
+
 			// interpolate drawings to make them more smooth (instead of drawing 1 cirlce per 1 frame)
 			cur := rl.NewVector2(a.mouseX, a.mouseY)
 
@@ -550,7 +865,7 @@ func (a *App) OnMousePress() {
 			dy := cur.Y - a.lastDrawnPixel.Y
 			dist := rl.Vector2Length(rl.NewVector2(dx, dy))
 
-			step := a.currentDrawRadius * 0.5
+			step := a.strokeRadius * 0.5
 			if step < 1 {
 				step = 1
 			}
@@ -560,18 +875,35 @@ func (a *App) OnMousePress() {
 				steps = 1
 			}
 
+			points := make([]rl.Vector2, 0, steps)
 			for i := 1; i <= steps; i++ {
 				t := float32(i) / float32(steps)
 				x := a.lastDrawnPixel.X + dx*t
 				y := a.lastDrawnPixel.Y + dy*t
 
-				a.mu.Lock()
-				a.drawnPixels = append(a.drawnPixels, rl.NewVector2(x, y))
-				a.mu.Unlock()
+				points = append(points, rl.NewVector2(x, y))
+			}
+
+			delta := StrokeDelta{
+				Author: a.clientID,
+				Stroke: a.nextStrokeID,
+				Seq:    a.strokeSeq,
+				Tool:   a.strokeTool,
+				Radius: a.strokeRadius,
+				Color:  a.strokeColor,
+				Time:   a.strokeTime,
+				Points: points,
 			}
+			a.strokeSeq += uint32(len(points))
+
+			a.mu.Lock()
+			a.canvas.Merge(delta)
+			a.queueDelta(delta)
+			a.mu.Unlock()
 
 			a.lastDrawnPixel = cur
 		} else {
+			a.isStroking = false
 			a.lastDrawnPixel = rl.NewVector2(a.mouseX, a.mouseY)
 		}
 	}
@@ -585,86 +917,89 @@ func (a *App) GetMousePos() {
 	a.mouseY = mousePos.Y
 }
 
-func (a *App) HandleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+// MakeRoom hosts a new room from this window, starting the room server if needed, and joins it
+func (a *App) MakeRoom(settings RoomSettings, canvas *Canvas) error {
+	if a.roomServer == nil {
+		rs, err := NewRoomServer(a.config)
+		if err != nil {
+			return err
+		}
+		a.roomServer = rs
+	}
+
+	settings.HostID = a.clientID
+	settings.HostToken = crand.Text()
+
+	room, err := a.roomServer.AddRoom(settings, canvas)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer ws.Close()
 
-	clientsMu.Lock()
-	clients[ws] = true
-	clientsMu.Unlock()
+	a.hostedRoom = room
+	a.isRoomHost = true
+	a.isServerBooted = true
 
-	for {
-		_, msg, err := ws.ReadMessage()
-		if err != nil {
-			fmt.Printf("error reading message from ws: %v\n", err)
-			clientsMu.Lock()
-			delete(clients, ws)
-			clientsMu.Unlock()
-			break
-		}
+	a.mu.Lock()
+	a.hostToken = settings.HostToken
+	a.mu.Unlock()
 
-		elemSize := binary.Size(rl.Vector2{})
-		if elemSize <= 0 || len(msg)%elemSize != 0 {
-			fmt.Printf("invalid vector payload size: msg=%d elem=%d\n", len(msg), elemSize)
-			continue
-		}
+	go a.JoinWsServer(a.config.SelfURL(room.path), settings.Password)
+	return nil
+}
 
-		count := len(msg) / elemSize
-		vectors := make([]rl.Vector2, count)
+// HostBackgroundRooms serves the rooms listed with -rooms. Nobody hosts them from a window, everyone joins as a client
+func (a *App) HostBackgroundRooms() error {
+	if len(a.config.Rooms) == 0 {
+		return nil
+	}
 
-		if err := binary.Read(bytes.NewReader(msg), binary.LittleEndian, vectors); err != nil {
-			fmt.Printf("failed to read vector data in ws message: %v\n", err)
-			continue
+	if a.roomServer == nil {
+		rs, err := NewRoomServer(a.config)
+		if err != nil {
+			return err
 		}
+		a.roomServer = rs
+	}
 
-		a.mu.Lock()
-		a.drawnPixels = vectors
-		a.mu.Unlock()
-
-		clientsMu.Lock()
-		for client := range clients {
-			if err := client.WriteMessage(websocket.BinaryMessage, msg); err != nil {
-				fmt.Printf("error writing message [%s]: %v\n", msg, err)
-				client.Close()
-				delete(clients, client)
-			}
+	for _, name := range a.config.Rooms {
+		if _, err := a.roomServer.AddRoom(RoomSettings{Name: name}, nil); err != nil {
+			return err
 		}
-		clientsMu.Unlock()
 	}
-}
 
-func (a *App) StartWsServer() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", a.HandleConnections)
+	return nil
+}
 
-	a.server = &http.Server{
-		Addr:    "0.0.0.0:8000",
-		Handler: mux,
+// end the room made from this window for everyone in it. The room server keeps running while other rooms are served
+func (a *App) CloseHostedRoom() {
+	if a.hostedRoom != nil {
+		a.roomServer.RemoveRoom(a.hostedRoom, "host closed the room")
+		a.hostedRoom = nil
 	}
+	a.stopIdleRoomServer()
+}
 
-	fmt.Println("Started WebSocket Server on :8000")
-	a.isServerBooted = true
-	if err := a.server.ListenAndServe(); err != nil {
-		log.Printf("server shutdown error: %v\n", err)
-		a.isServerBooted = false
+// stop hosting the room made from this window, handing it over to someone still in it. It only ends for everyone when
+// nobody can take over
+func (a *App) HandOffHostedRoom() {
+	if a.hostedRoom != nil {
+		a.roomServer.HandOffRoom(a.hostedRoom)
+		a.hostedRoom = nil
 	}
+	a.stopIdleRoomServer()
 }
 
-func (a *App) StartMDNS() {
-	hostName, _ := os.Hostname()
-
-	info := []string{"Picto-Chat Server"}
-	service, _ := mdns.NewMDNSService(hostName, "_pictochat._tcp", "", "", 8000, nil, info)
+// shut the room server down once it serves no room
+func (a *App) stopIdleRoomServer() {
+	if a.roomServer != nil && a.roomServer.RoomCount() == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
 
-	fmt.Println("Starting MDNS Server...")
-	a.MDNSServer, _ = mdns.NewServer(&mdns.Config{Zone: service})
+		a.roomServer.Shutdown(ctx)
+		a.roomServer = nil
+	}
 
-	go func() {
-		a.StartWsServer()
-	}()
+	a.isServerBooted = false
 }
 
 func (a *App) MDNSLookup() {
@@ -676,13 +1011,22 @@ func (a *App) MDNSLookup() {
 				continue
 			}
 
-			if entry.Port != 8000 {
+			// rooms can be hosted on any port, a Picto-Chat room is told apart by the path and protocol in its TXT record
+			txt := parseTXT(entry.InfoFields)
+			if _, ok := txt["proto"]; !ok || !strings.HasPrefix(txt["path"], roomPathPrefix) {
 				continue
 			}
 
 			fmt.Printf("Found new entry: %v\n", entry)
-			room := Room{hostName: entry.Host, Addr: entry.AddrV4.String(), Port: entry.Port}
-			room.URL = fmt.Sprintf("ws://%s:%d/ws", room.Addr, room.Port)
+
+			room := Room{hostName: entry.Host, Name: txt["name"], Addr: entry.AddrV4.String(), Port: entry.Port, Path: txt["path"], Topic: txt["topic"], Tag: txt["tag"]}
+			room.Proto, _ = strconv.Atoi(txt["proto"])
+			room.Participants, _ = strconv.Atoi(txt["count"])
+			room.Locked, _ = strconv.ParseBool(txt["locked"])
+			if room.Name == "" {
+				room.Name = entry.Host
+			}
+			room.URL = fmt.Sprintf("ws://%s%s", net.JoinHostPort(room.Addr, strconv.Itoa(room.Port)), room.Path)
 			newRooms = append(newRooms, room)
 		}
 		a.mu.Lock()
@@ -694,96 +1038,240 @@ func (a *App) MDNSLookup() {
 	close(entriesCH)
 }
 
-func (a *App) JoinWsServer(roomAddr string) {
+// JoinWsServer connects to a room, password is only sent when not empty. Once in, the connection is supervised: when
+// it drops we keep redialing until we are back or the user leaves the room
+func (a *App) JoinWsServer(roomAddr string, password string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// only one room connection at a time, a supervisor left behind would keep merging its room into our canvas
+	a.mu.Lock()
+	if a.leaveRoom != nil {
+		a.leaveRoom()
+	}
+	a.leaveRoom = cancel
+	a.mu.Unlock()
+
 	var c *websocket.Conn
+	var welcome Welcome
 	var err error
 
-	// retry connection 3 times with a 200 ms pause in between (helps with host connection)
+	// retry connection 3 times with a 300 ms pause in between (helps with host connection)
 	for i := 0; i < 3; i++ {
-		c, _, err = websocket.DefaultDialer.Dial(roomAddr, nil)
-		if err != nil {
-			log.Printf("failed to connect to web socket server: %v", err)
-			time.Sleep(300 * time.Millisecond)
-			continue
+		c, welcome, err = a.dialRoom(ctx, roomAddr, password, "")
+		if err == nil {
+			break
 		}
-		break
+		log.Printf("failed to connect to web socket server: %v", err)
+
+		// the host answered and said no, asking again wont change its mind
+		if isRefused(err) {
+			break
+		}
+		time.Sleep(300 * time.Millisecond)
 	}
 
-	// store the connection as App field
+	// a cancelled join was already cleaned up by LeaveRoom
 	a.mu.Lock()
-	if c != nil {
-		a.ws = c
-		a.isServerBooted = true
-	} else {
-		return
+	if ctx.Err() == nil {
+		a.joining = false
 	}
 	a.mu.Unlock()
 
+	if err != nil {
+		a.mu.Lock()
+		a.joinErr = fmt.Sprintf("Could not reach room: %v", err)
+
+		var refused *refusedError
+		if errors.As(err, &refused) {
+			a.joinErr = fmt.Sprintf("Could not join room: %s", refused.reason)
+		}
+		a.currentRoom = Room{}
+		a.mu.Unlock()
+		return
+	}
+
+	if !a.setConn(ctx, c, welcome) {
+		c.Close()
+		return
+	}
+
 	fmt.Println("Connected to WebSocket Server")
+	a.superviseConn(ctx, c, roomAddr, password)
+}
 
+// read and apply messages from the room until the connection fails
+func (a *App) readRoom(c *websocket.Conn) error {
 	// continuosly read messages received from the server
 	for {
-		_, msg, err := c.ReadMessage()
+		env, err := readEnvelope(c)
 		if err != nil {
-			fmt.Printf("failed to read messages from ws: %v\n", err)
-			break
+			return err
 		}
 
-		elemSize := binary.Size(rl.Vector2{})
-		if elemSize <= 0 || len(msg)%elemSize != 0 {
-			fmt.Printf("invalid vector payload size: msg=%d elem=%d\n", len(msg), elemSize)
+		if env.Version != ProtocolVersion {
+			fmt.Printf("dropping %s message with version %d\n", env.Type, env.Version)
 			continue
 		}
 
-		count := len(msg) / elemSize
-		vectors := make([]rl.Vector2, count)
+		switch env.Type {
+		case MsgStrokeDelta:
+			var delta StrokeDelta
+			if err := delta.UnmarshalBinary(env.Payload); err != nil {
+				fmt.Printf("failed to read stroke delta in ws message: %v\n", err)
+				continue
+			}
+			delta.Author = env.Sender
 
-		if err := binary.Read(bytes.NewReader(msg), binary.LittleEndian, vectors); err != nil {
-			fmt.Printf("failed to read vector data in ws message: %v\n", err)
-			continue
-		}
+			// merge the remote points into our canvas instead of replacing it, so concurrent drawers dont erase each other
+			a.mu.Lock()
+			a.canvas.Merge(delta)
+			a.mu.Unlock()
 
-		a.mu.Lock()
-		a.drawnPixels = vectors
-		a.mu.Unlock()
+		case MsgUndo, MsgRedo:
+			id, err := parseStrokeRef(env.Payload)
+			if err != nil {
+				fmt.Printf("failed to read stroke reference in ws message: %v\n", err)
+				continue
+			}
+
+			a.mu.Lock()
+			a.canvas.SetUndone(env.Sender, id, env.Type == MsgUndo)
+			a.mu.Unlock()
+
+		// full canvas sent by the host when we join or when it opens a saved canvas, replaces whatever we had
+		case MsgSnapshot:
+			snapshot := NewCanvas()
+			if err := snapshot.UnmarshalBinary(env.Payload); err != nil {
+				fmt.Printf("failed to read canvas snapshot in ws message: %v\n", err)
+				continue
+			}
+
+			// after a reconnect, points drawn while we were away are still waiting in the outbox. Put them back on top so
+			// they dont disappear until the host relays them
+			a.mu.Lock()
+			for _, item := range a.outbox {
+				if item.delta != nil {
+					snapshot.Merge(*item.delta)
+				}
+			}
+			a.canvas = snapshot
+
+			// a canvas opened by the host may not have our strokes, undo and redo must only send ids the host knows
+			a.undoStack = a.knownStrokes(a.undoStack)
+			a.redoStack = a.knownStrokes(a.redoStack)
+			a.mu.Unlock()
+
+		// someone joined or left, possibly evicted after missing too many pings
+		case MsgPresence:
+			var presence Presence
+			if err := json.Unmarshal(env.Payload, &presence); err != nil {
+				fmt.Printf("failed to read presence in ws message: %v\n", err)
+				continue
+			}
+
+			a.mu.Lock()
+			previous := a.participants
+			a.participants = presence.Participants
+			a.mu.Unlock()
+
+			// say who came and went, the first list after joining only tells us who was already here
+			if presence.Left != 0 {
+				name := guestName(presence.Left)
+				if p, ok := findParticipant(previous, presence.Left); ok {
+					name = participantName(p)
+				}
+
+				if presence.TimedOut {
+					a.ShowNotice(fmt.Sprintf("%s lost connection", name))
+				} else {
+					a.ShowNotice(fmt.Sprintf("%s left", name))
+				}
+			} else if len(previous) > 0 {
+				for _, p := range presence.Participants {
+					if _, ok := findParticipant(previous, p.ID); !ok && p.ID != a.clientID {
+						a.ShowNotice(fmt.Sprintf("%s joined", participantName(p)))
+					}
+				}
+			}
+
+		// the host echoes clears to everyone, including whoever asked for it
+		case MsgClear:
+			a.mu.Lock()
+			a.canvas.Clear()
+			a.undoStack = nil
+			a.redoStack = nil
+			a.mu.Unlock()
+
+		// the host is about to disconnect everyone, stop here instead of treating it as a lost connection
+		case MsgRoomClosed:
+			return &roomClosedError{reason: string(env.Payload)}
+
+		// the host left and picked who hosts the room now, the supervisor moves us over
+		case MsgHandoff:
+			var handoff Handoff
+			if err := json.Unmarshal(env.Payload, &handoff); err != nil {
+				fmt.Printf("failed to read handoff in ws message: %v\n", err)
+				continue
+			}
+			return &handoffError{handoff: handoff}
 
+		default:
+			fmt.Printf("ignoring unexpected %s message\n", env.Type)
+		}
 	}
 }
 
-func (a *App) SendDrawingsToWs() {
-	a.mu.RLock()
+// ask the host to clear the canvas for the whole room. It is queued behind our pending points so they are cleared too
+func (a *App) SendClearToWs() {
+	a.mu.Lock()
+	a.queueMessage(MsgClear, nil)
+	a.mu.Unlock()
+}
 
-	// make sure connection is valid
-	if a.ws == nil {
-		return
-	}
+// tell the room one of our strokes was undone or redone. It is queued behind our pending points so the stroke exists for everyone
+func (a *App) SendStrokeRefToWs(t MsgType, id uint32) {
+	a.mu.Lock()
+	a.queueMessage(t, strokeRefPayload(id))
+	a.mu.Unlock()
+}
 
-	pixels := make([]rl.Vector2, len(a.drawnPixels))
-	copy(pixels, a.drawnPixels)
-	a.mu.RUnlock()
+func main() {
+	config, err := ParseConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// convert the slice of vectors into bytes
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.LittleEndian, pixels); err != nil {
-		fmt.Printf("failed to write a.drawnPixels to bytes: %v\n", err)
+	// convert a saved canvas without opening a window
+	if config.ConvertSVG != "" {
+		out := strings.TrimSuffix(config.ConvertSVG, filepath.Ext(config.ConvertSVG)) + ".svg"
+		if err := ConvertCanvasToSVG(config.ConvertSVG, out); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Wrote %s\n", out)
 		return
 	}
 
-	// send the bytes to the server
-	if err := a.ws.WriteMessage(websocket.BinaryMessage, buf.Bytes()); err != nil {
-		fmt.Printf("failed to write bytes to ws: %v\n", err)
+	// dedicated server, raylib is never initialised
+	if config.Headless {
+		if err := RunHeadless(config); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-}
 
-func main() {
 	rl.InitWindow(screenWidth, screenHeight, "Picto-Chat")
 	defer rl.CloseWindow()
 
 	rl.SetTargetFPS(60)
 
 	var app App
+	app.config = config
 	app.Init()
 
+	if err := app.HostBackgroundRooms(); err != nil {
+		log.Fatal(err)
+	}
+
 	// unload the loaded fonts
 	defer rl.UnloadFont(app.font.Regular)
 	defer rl.UnloadFont(app.font.Bold)
@@ -799,6 +1287,12 @@ func main() {
 
 		rl.EndDrawing()
 	}
+
+	// quitting while hosting hands the room over instead of ending it for everyone, unless nobody can take over
+	if app.hostedRoom != nil {
+		app.LeaveRoom()
+		app.HandOffHostedRoom()
+	}
 }
 
 // helper function to center drawn text
@@ -809,6 +1303,15 @@ func drawTextCentered(font rl.Font, text string, y int, fontSize float32, color
 	rl.DrawTextEx(font, text, rl.NewVector2(x, float32(y)), fontSize, 1, color)
 }
 
+// true while Ctrl (or Cmd on macOS) is held, used for shortcuts
+func isCtrlDown() bool {
+	return rl.IsKeyDown(rl.KeyLeftControl) || rl.IsKeyDown(rl.KeyRightControl) || rl.IsKeyDown(rl.KeyLeftSuper) || rl.IsKeyDown(rl.KeyRightSuper)
+}
+
+func isShiftDown() bool {
+	return rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift)
+}
+
 // ensure all characters are rendered correctly
 func codePoints() []int32 {
 	cps := make([]int32, 0, 96+96+128)
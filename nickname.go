@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// longest nickname, in runes
+const maxNicknameLen = 16
+
+// 'Back' and 'Continue' buttons under the nickname field
+func nicknameBackRect() rl.Rectangle {
+	return rl.NewRectangle((screenWidth/2)-280, (screenHeight/2)+80, float32(250), float32(100))
+}
+
+func nicknameContinueRect() rl.Rectangle {
+	return rl.NewRectangle((screenWidth/2)+30, (screenHeight/2)+80, float32(250), float32(100))
+}
+
+// file holding the nickname picked last time, next to the config file
+func nicknamePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "picto-chat", "nickname")
+}
+
+// nickname picked last time, or the name of the user when there is none yet
+func loadNickname() string {
+	if path := nicknamePath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return cleanNickname(string(data))
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("failed to read nickname: %v\n", err)
+		}
+	}
+
+	name := os.Getenv("USER")
+	if name == "" {
+		name = os.Getenv("USERNAME") // windows
+	}
+	return cleanNickname(name)
+}
+
+func saveNickname(name string) error {
+	path := nicknamePath()
+	if path == "" {
+		return errors.New("no config directory to save the nickname in")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(name), 0o644)
+}
+
+// nickname without control characters or surrounding spaces, cut to maxNicknameLen. Also applied by the host to the
+// names clients send, so nobody can stretch the participant list
+func cleanNickname(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return -1
+		}
+		return r
+	}, name)
+
+	if runes := []rune(strings.TrimSpace(name)); len(runes) > maxNicknameLen {
+		name = string(runes[:maxNicknameLen])
+	}
+	return strings.TrimSpace(name)
+}
+
+// name shown for a client that didnt pick one
+func guestName(id uint32) string {
+	return fmt.Sprintf("Guest %04d", id%10000)
+}
+
+// the nickname prompt shown before picking a room, filled with the last nickname
+func (a *App) OpenNicknamePrompt() {
+	rec := rl.NewRectangle((screenWidth/2)-300, (screenHeight/2)-60, float32(600), float32(60))
+	a.nicknameForm = &Form{Inputs: []*TextInput{
+		{Label: "Nickname", Placeholder: "How others see you", Value: loadNickname(), MaxLen: maxNicknameLen, Rect: rec},
+	}}
+	a.joinErr = ""
+	a.currentAppState = AppStateNickname
+}
+
+func (a *App) DrawNickname() {
+	drawTextCentered(a.font.Regular, "Pick a nickname...", (screenHeight/2)-220, 50, rl.White)
+
+	a.nicknameForm.Draw(a.font.Italic)
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	drawButton(a.font.BoldItalic, nicknameBackRect(), "Back", rl.CheckCollisionPointRec(mouse, nicknameBackRect()))
+	drawButton(a.font.BoldItalic, nicknameContinueRect(), "Continue", rl.CheckCollisionPointRec(mouse, nicknameContinueRect()))
+
+	if a.joinErr != "" {
+		drawTextCentered(a.font.Italic, a.joinErr, screenHeight-100, 25, rl.Red)
+	} else {
+		drawTextCentered(a.font.Italic, "Shown to everyone in the rooms you join   [Enter] continue", screenHeight-100, 25, rl.DarkGray)
+	}
+}
+
+// typing goes to the nickname, [Enter] or 'Continue' keeps it and moves on to the room options
+func (a *App) UpdateNickname() {
+	a.GetMousePos()
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	a.nicknameForm.Update(mouse)
+
+	if rl.IsMouseButtonReleased(rl.MouseButtonLeft) && rl.CheckCollisionPointRec(mouse, nicknameBackRect()) {
+		a.joinErr = ""
+		a.currentAppState = AppStateStart
+		return
+	}
+
+	done := rl.IsKeyPressed(rl.KeyEnter) || rl.IsKeyPressed(rl.KeyKpEnter)
+	if rl.IsMouseButtonReleased(rl.MouseButtonLeft) && rl.CheckCollisionPointRec(mouse, nicknameContinueRect()) {
+		done = true
+	}
+
+	if !done {
+		return
+	}
+
+	name := cleanNickname(a.nicknameForm.Inputs[0].Value)
+	if name == "" {
+		a.joinErr = "Pick a nickname first"
+		return
+	}
+
+	a.mu.Lock()
+	a.nickname = name
+	a.mu.Unlock()
+
+	if err := saveNickname(name); err != nil {
+		fmt.Printf("failed to save nickname: %v\n", err)
+	}
+
+	a.joinErr = ""
+	a.currentAppState = AppStateRoomConfig
+}
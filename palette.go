@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// colors offered in the palette. One more swatch after these holds the custom color picked with the RGB sliders
+var paletteColors = []rl.Color{rl.White, rl.Red, rl.Orange, rl.Yellow, rl.Green, rl.SkyBlue, rl.Blue, rl.Purple}
+
+const (
+	swatchRadius  = 16
+	swatchSpacing = 50
+	noSlider      = -1
+)
+
+// 'Drawing Tools' box holding the radius selectors
+func drawingToolsRect() rl.Rectangle {
+	return rl.NewRectangle(float32(40), float32(screenHeight)-150, float32(450), float32(100))
+}
+
+// 'Colors' box next to the drawing tools
+func paletteRect() rl.Rectangle {
+	return rl.NewRectangle(float32(510), float32(screenHeight)-150, float32(480), float32(100))
+}
+
+// custom color popup shown above the palette
+func colorPickerRect() rl.Rectangle {
+	return rl.NewRectangle(float32(510), float32(screenHeight)-360, float32(480), float32(160))
+}
+
+// eraser button at the end of the drawing tools box
+func eraserRect() rl.Rectangle {
+	rec := drawingToolsRect()
+	return rl.NewRectangle(rec.X+rec.Width-95, rec.Y+(rec.Height/2)-18, float32(60), float32(36))
+}
+
+// center of the i-th swatch, the custom swatch is at index len(paletteColors)
+func swatchCenter(i int) rl.Vector2 {
+	rec := paletteRect()
+	return rl.NewVector2(rec.X+40+float32(i*swatchSpacing), rec.Y+(rec.Height/2))
+}
+
+// track of the i-th RGB slider in the color picker
+func sliderRect(i int) rl.Rectangle {
+	rec := colorPickerRect()
+	return rl.NewRectangle(rec.X+60, rec.Y+30+float32(i*45), float32(280), float32(12))
+}
+
+// true when the mouse is over any of the tool panels, so clicking a tool doesnt also draw on the canvas
+func (a *App) isOverTools() bool {
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+
+	if rl.CheckCollisionPointRec(mouse, drawingToolsRect()) || rl.CheckCollisionPointRec(mouse, paletteRect()) {
+		return true
+	}
+
+	return a.showColorPicker && rl.CheckCollisionPointRec(mouse, colorPickerRect())
+}
+
+// draw the color swatches and, when open, the custom RGB picker
+func (a *App) DrawPalette() {
+	insertRec := paletteRect()
+	swatchContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
+
+	rl.DrawTextEx(a.font.Italic, "Colors", rl.NewVector2(insertRec.X+180, insertRec.Y-40), 35, 2, rl.White)
+	rl.DrawRectangleRounded(insertRec, float32(0.5), int32(0), rl.White)
+	rl.DrawRectangleRounded(swatchContainer, float32(0.5), int32(0), rl.Black)
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+
+	for i := 0; i <= len(paletteColors); i++ {
+		center := swatchCenter(i)
+
+		color := a.customColor
+		if i < len(paletteColors) {
+			color = paletteColors[i]
+		}
+
+		// ring around the selected swatch (only while the pen is active), grey ring on hover
+		if i == a.paletteIndex && a.currentTool == ToolPen {
+			rl.DrawCircleV(center, swatchRadius+5, rl.Blue)
+		} else if rl.CheckCollisionPointCircle(mouse, center, swatchRadius) {
+			rl.DrawCircleV(center, swatchRadius+5, rl.Gray)
+		}
+
+		rl.DrawCircleV(center, swatchRadius, color)
+
+		// outline the custom swatch so it reads as a different kind of button
+		if i == len(paletteColors) {
+			rl.DrawCircleLines(int32(center.X), int32(center.Y), swatchRadius+2, rl.White)
+		}
+	}
+
+	if !a.showColorPicker {
+		return
+	}
+
+	pickerRec := colorPickerRect()
+	pickerContainer := rl.NewRectangle(pickerRec.X+5, pickerRec.Y+5, pickerRec.Width-10, pickerRec.Height-10)
+
+	rl.DrawRectangleRounded(pickerRec, float32(0.2), int32(0), rl.White)
+	rl.DrawRectangleRounded(pickerContainer, float32(0.2), int32(0), rl.Black)
+
+	labels := []string{"R", "G", "B"}
+	values := []uint8{a.customColor.R, a.customColor.G, a.customColor.B}
+
+	for i, label := range labels {
+		track := sliderRect(i)
+		knobX := track.X + (float32(values[i])/255)*track.Width
+
+		rl.DrawTextEx(a.font.Italic, label, rl.NewVector2(pickerRec.X+25, track.Y-12), 35, 2, rl.White)
+		rl.DrawRectangleRounded(track, float32(1), int32(0), rl.DarkGray)
+		rl.DrawCircle(int32(knobX), int32(track.Y+(track.Height/2)), 10, rl.White)
+		rl.DrawTextEx(a.font.Italic, fmt.Sprintf("%d", values[i]), rl.NewVector2(track.X+track.Width+20, track.Y-12), 35, 2, rl.White)
+	}
+
+	// preview of the custom color
+	rl.DrawCircle(int32(pickerRec.X+pickerRec.Width-45), int32(pickerRec.Y+(pickerRec.Height/2)), 25, a.customColor)
+}
+
+// handle swatch clicks and slider drags
+func (a *App) UpdatePalette() {
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+
+	if rl.IsMouseButtonPressed(rl.MouseButtonLeft) {
+		for i := 0; i <= len(paletteColors); i++ {
+			if !rl.CheckCollisionPointCircle(mouse, swatchCenter(i), swatchRadius) {
+				continue
+			}
+
+			// picking a color always goes back to the pen
+			a.paletteIndex = i
+			a.currentTool = ToolPen
+			if i < len(paletteColors) {
+				a.currentDrawColor = paletteColors[i]
+				a.showColorPicker = false
+			} else {
+				// clicking the custom swatch selects it and toggles the RGB picker
+				a.currentDrawColor = a.customColor
+				a.showColorPicker = !a.showColorPicker
+			}
+		}
+
+		// grab a slider, padding the track vertically so the thin bar is easy to hit
+		if a.showColorPicker {
+			for i := 0; i < 3; i++ {
+				track := sliderRect(i)
+				hitbox := rl.NewRectangle(track.X-10, track.Y-12, track.Width+20, track.Height+24)
+				if rl.CheckCollisionPointRec(mouse, hitbox) {
+					a.activeSlider = i
+				}
+			}
+		}
+	}
+
+	if !rl.IsMouseButtonDown(rl.MouseButtonLeft) {
+		a.activeSlider = noSlider
+		return
+	}
+
+	if a.activeSlider == noSlider {
+		return
+	}
+
+	// keep following the mouse while the slider is held, even outside the track
+	track := sliderRect(a.activeSlider)
+	t := (a.mouseX - track.X) / track.Width
+	if t < 0 {
+		t = 0
+	}
+	if t > 1 {
+		t = 1
+	}
+	value := uint8(t * 255)
+
+	switch a.activeSlider {
+	case 0:
+		a.customColor.R = value
+	case 1:
+		a.customColor.G = value
+	case 2:
+		a.customColor.B = value
+	}
+
+	a.paletteIndex = len(paletteColors)
+	a.currentTool = ToolPen
+	a.currentDrawColor = a.customColor
+}
+
+// draw the eraser button, blue when selected or hovered like the radius selectors
+func (a *App) DrawEraser() {
+	rec := eraserRect()
+
+	color := rl.White
+	if a.currentTool == ToolEraser || rl.CheckCollisionPointRec(rl.NewVector2(a.mouseX, a.mouseY), rec) {
+		color = rl.Blue
+	}
+
+	// eraser body with a black band across it
+	rl.DrawRectangleRounded(rec, float32(0.4), int32(0), color)
+	rl.DrawRectangleRec(rl.NewRectangle(rec.X+(rec.Width/3), rec.Y, float32(6), rec.Height), rl.Black)
+}
+
+// toggle the eraser when its button is clicked
+func (a *App) UpdateEraser() {
+	if !rl.IsMouseButtonPressed(rl.MouseButtonLeft) {
+		return
+	}
+
+	if !rl.CheckCollisionPointRec(rl.NewVector2(a.mouseX, a.mouseY), eraserRect()) {
+		return
+	}
+
+	if a.currentTool == ToolEraser {
+		a.currentTool = ToolPen
+	} else {
+		a.currentTool = ToolEraser
+		a.showColorPicker = false
+	}
+}
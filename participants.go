@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// most participants listed by name, the rest are summed up
+const maxListedParticipants = 12
+
+// panel on the right of the canvas listing everyone in the room in the order they joined, with the host marked
+func (a *App) DrawParticipantList() {
+	a.mu.RLock()
+	participants := a.participants
+	hostID := a.roomRules.HostID
+	a.mu.RUnlock()
+
+	if len(participants) == 0 {
+		return
+	}
+
+	rows := min(len(participants), maxListedParticipants)
+	more := len(participants) - rows
+
+	height := 55 + rows*30
+	if more > 0 {
+		height += 30
+	}
+
+	panel := rl.NewRectangle(screenWidth-480, 170, float32(460), float32(height))
+	rl.DrawRectangleRounded(panel, float32(0.1), int32(0), rl.Fade(rl.DarkGray, 0.6))
+	rl.DrawTextEx(a.font.Italic, fmt.Sprintf("In the room (%d)", len(participants)), rl.NewVector2(panel.X+15, panel.Y+10), 28, 2, rl.White)
+
+	y := panel.Y + 50
+	for _, p := range participants[:rows] {
+		name := participantName(p)
+		color := rl.White
+		if p.ID == a.clientID {
+			name += " (you)"
+			color = rl.SkyBlue
+		}
+
+		pos := rl.NewVector2(panel.X+15, y)
+		rl.DrawTextEx(a.font.Italic, name, pos, 20, 1, color)
+
+		// red badge right after the name of the host
+		if hostID != 0 && p.ID == hostID {
+			nameSize := rl.MeasureTextEx(a.font.Italic, name, 20, 1)
+			badge := rl.NewRectangle(pos.X+nameSize.X+8, y+2, float32(56), float32(22))
+			rl.DrawRectangleRounded(badge, float32(0.5), int32(0), rl.Red)
+			rl.DrawTextEx(a.font.Bold, "HOST", rl.NewVector2(badge.X+8, badge.Y+2), 16, 1, rl.White)
+		}
+
+		joined := time.UnixMilli(p.Joined).Format("15:04")
+		rl.DrawTextEx(a.font.Italic, joined, rl.NewVector2(panel.X+panel.Width-70, y), 20, 1, rl.Gray)
+		y += 30
+	}
+
+	if more > 0 {
+		rl.DrawTextEx(a.font.Italic, fmt.Sprintf("+%d more", more), rl.NewVector2(panel.X+15, y), 20, 1, rl.Gray)
+	}
+}
+
+// name to show for a participant, hosts older than nicknames dont send one
+func participantName(p Participant) string {
+	if p.Name == "" {
+		return guestName(p.ID)
+	}
+	return p.Name
+}
+
+// name of someone in the participant list, for notices about them
+func findParticipant(participants []Participant, id uint32) (Participant, bool) {
+	for _, p := range participants {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Participant{}, false
+}
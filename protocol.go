@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is bumped whenever the wire format changes in a way older clients cant read
+const ProtocolVersion uint8 = 12
+
+// how long either side waits for the other half of the join handshake
+const handshakeTimeout = 5 * time.Second
+
+// heartbeat, both sides ping each other so a peer that vanished without closing the connection is noticed
+const (
+	pingInterval = 10 * time.Second // how often we ping the other side
+	pongWait     = 25 * time.Second // a peer that hasnt answered our pings for this long is gone
+	writeWait    = 5 * time.Second  // deadline for writing a ping
+)
+
+// header carrying the room password when dialing a locked room
+const passwordHeader = "X-Picto-Password"
+
+// header carrying the secret the host window was given when it made the room, it proves the connection is the host
+const hostTokenHeader = "X-Picto-Host-Token"
+
+// size of the envelope header: version (1) + type (1) + sender (4)
+const envelopeHeaderSize = 6
+
+type MsgType uint8
+
+const (
+	MsgHello       MsgType = iota + 1 // first message sent by a client after connecting
+	MsgWelcome                        // host accepted the hello, live updates follow
+	MsgReject                         // host refused the hello, payload is the reason as text
+	MsgStrokeDelta                    // points appended to a stroke, payload is a StrokeDelta
+	MsgClear                          // wipe the canvas for everyone, no payload
+	MsgUndo                           // hide one of the sender's strokes, payload is the stroke id
+	MsgRedo                           // show an undone stroke of the sender again, payload is the stroke id
+	MsgSnapshot                       // whole canvas, payload is a marshalled Canvas. Sent by the host to new clients, or by the host's window to replace the room canvas
+	MsgPresence                       // someone joined or left, payload is a JSON Presence. Sent by the host to everyone in the room
+	MsgRoomClosed                     // the host ended the room, payload is the reason as text. Sent right before everyone is disconnected
+	MsgHandoff                        // the host left and passed the room on, payload is a JSON Handoff. Sent right before everyone is disconnected
+)
+
+func (t MsgType) String() string {
+	switch t {
+	case MsgHello:
+		return "hello"
+	case MsgWelcome:
+		return "welcome"
+	case MsgReject:
+		return "reject"
+	case MsgStrokeDelta:
+		return "stroke-delta"
+	case MsgClear:
+		return "clear"
+	case MsgUndo:
+		return "undo"
+	case MsgRedo:
+		return "redo"
+	case MsgSnapshot:
+		return "snapshot"
+	case MsgPresence:
+		return "presence"
+	case MsgRoomClosed:
+		return "room-closed"
+	case MsgHandoff:
+		return "handoff"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// Hello is the JSON payload of MsgHello. Older clients send none
+type Hello struct {
+	Name   string `json:"name,omitempty"`   // nickname picked by the user
+	Port   int    `json:"port,omitempty"`   // port the client would serve the room on if it took over from the host
+	Rejoin string `json:"rejoin,omitempty"` // secret from the welcome of the connection this one replaces, empty on a first join
+}
+
+// Welcome is the JSON payload of MsgWelcome, telling a new client the rules of the room
+type Welcome struct {
+	HostOnlyClear bool   `json:"hostOnlyClear"`    // only the host may send MsgClear
+	HostID        uint32 `json:"hostId,omitempty"` // client id of the host's own window, 0 when nobody hosts the room
+	Rejoin        string `json:"rejoin,omitempty"` // secret for this client only, its hello presents it when reconnecting
+}
+
+// Participant is one client connected to a room
+type Participant struct {
+	ID     uint32 `json:"id"`
+	Name   string `json:"name"`
+	Joined int64  `json:"joined"`         // unix milliseconds
+	Addr   string `json:"addr,omitempty"` // host:port the client would serve the room on, empty when it cant take over
+}
+
+// Handoff is the JSON payload of MsgHandoff. The successor hosts the room from now on and everyone else reconnects to
+// it. Only the successor gets the password and the canvas, the others already know the password they joined with
+type Handoff struct {
+	Successor     Participant `json:"successor"`
+	Path          string      `json:"path"` // the successor serves the room on the same path, everyone else dials it there
+	Name          string      `json:"name"`
+	Topic         string      `json:"topic,omitempty"`
+	Tag           string      `json:"tag,omitempty"`
+	Password      string      `json:"password,omitempty"`
+	HostOnlyClear bool        `json:"hostOnlyClear,omitempty"`
+	Canvas        []byte      `json:"canvas,omitempty"` // marshalled Canvas
+}
+
+// pick who takes the room over from its participants, skipping everyone in gone. Every client runs this on its own copy
+// of the participant list, so they agree without asking each other: whoever can serve the room and has been in it the
+// longest wins, the lowest id on ties. Join times come from the host, so a client that missed the latest join still
+// picks the same successor, the newcomer only wins once everyone older is gone. A client that missed a leave picks
+// the leaver first, finds it unreachable and moves on to the same successor as everyone else
+func electSuccessor(participants []Participant, gone map[uint32]bool) (Participant, bool) {
+	var successor Participant
+	found := false
+	for _, p := range participants {
+		if p.Addr == "" || gone[p.ID] {
+			continue
+		}
+		if !found || p.Joined < successor.Joined || (p.Joined == successor.Joined && p.ID < successor.ID) {
+			successor = p
+			found = true
+		}
+	}
+	return successor, found
+}
+
+// Presence is the JSON payload of MsgPresence, the participants after the change and who left, if anyone did
+type Presence struct {
+	Participants []Participant `json:"participants"`
+	Left         uint32        `json:"left,omitempty"`     // client that just left, 0 when someone joined
+	TimedOut     bool          `json:"timedOut,omitempty"` // Left stopped answering pings and was evicted
+}
+
+var ErrShortEnvelope = errors.New("message shorter than envelope header")
+
+// Envelope frames every message sent over the room WebSocket
+type Envelope struct {
+	Version uint8
+	Type    MsgType
+	Sender  uint32 // client id of the original author, preserved when the host relays a message
+	Payload []byte
+}
+
+func NewEnvelope(t MsgType, sender uint32, payload []byte) Envelope {
+	return Envelope{Version: ProtocolVersion, Type: t, Sender: sender, Payload: payload}
+}
+
+// encode the envelope as [version][type][sender, little endian][payload...]
+func (e Envelope) MarshalBinary() ([]byte, error) {
+	msg := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(e.Payload))
+
+	msg[0] = e.Version
+	msg[1] = byte(e.Type)
+	binary.LittleEndian.PutUint32(msg[2:6], e.Sender)
+
+	return append(msg, e.Payload...), nil
+}
+
+// decode an envelope. The version is not checked here so callers can report a mismatch to the user
+func (e *Envelope) UnmarshalBinary(msg []byte) error {
+	if len(msg) < envelopeHeaderSize {
+		return fmt.Errorf("%w: %d bytes", ErrShortEnvelope, len(msg))
+	}
+
+	*e = Envelope{
+		Version: msg[0],
+		Type:    MsgType(msg[1]),
+		Sender:  binary.LittleEndian.Uint32(msg[2:6]),
+		Payload: msg[envelopeHeaderSize:],
+	}
+	return nil
+}
+
+// payload of undo and redo messages, the author of the stroke is the envelope sender
+func strokeRefPayload(id uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, id)
+}
+
+func parseStrokeRef(payload []byte) (uint32, error) {
+	if len(payload) != 4 {
+		return 0, fmt.Errorf("invalid stroke reference size: %d", len(payload))
+	}
+	return binary.LittleEndian.Uint32(payload), nil
+}
+
+// error returned to the user when the host and client cant talk to each other
+func versionMismatchError(local, remote uint8) error {
+	return fmt.Errorf("incompatible Picto-Chat versions (you: v%d, host: v%d), please update", local, remote)
+}
+
+// explain a failed dial. The host answers a refused upgrade (wrong password, unknown room) with a plain HTTP error,
+// its body is more useful to the user than websocket.ErrBadHandshake
+func dialError(resp *http.Response, err error) error {
+	if !errors.Is(err, websocket.ErrBadHandshake) || resp == nil {
+		return err
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	resp.Body.Close()
+
+	reason := strings.TrimSpace(string(body))
+	if reason == "" {
+		reason = resp.Status
+	}
+	return &refusedError{reason: reason}
+}
+
+// refusedError is a dial or hello the host answered but refused, retrying wont help
+type refusedError struct {
+	reason string
+}
+
+func (e *refusedError) Error() string {
+	return fmt.Sprintf("host refused connection: %s", e.reason)
+}
+
+func isRefused(err error) bool {
+	var refused *refusedError
+	return errors.As(err, &refused)
+}
+
+// roomClosedError is returned by the reader when the host sent MsgRoomClosed
+type roomClosedError struct {
+	reason string
+}
+
+func (e *roomClosedError) Error() string {
+	return fmt.Sprintf("room closed: %s", e.reason)
+}
+
+// handoffError is returned by the reader when the host passed the room on with MsgHandoff
+type handoffError struct {
+	handoff Handoff
+}
+
+func (e *handoffError) Error() string {
+	return fmt.Sprintf("host handed the room over to client %d", e.handoff.Successor.ID)
+}
+
+// reason the host gave for ending the room, ok is false when err is any other lost connection. A host that shut down
+// without MsgRoomClosed still sends a going away close frame
+func roomClosed(err error) (reason string, ok bool) {
+	var closed *roomClosedError
+	if errors.As(err, &closed) {
+		return closed.reason, true
+	}
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code == websocket.CloseGoingAway {
+		return closeErr.Text, true
+	}
+
+	return "", false
+}
+
+// ping the peer every pingInterval and make reads fail once it hasnt answered for pongWait. Call the returned func
+// when done with the connection to stop the pings
+func keepAlive(ws *websocket.Conn) func() {
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// WriteControl is safe to call alongside the other writers of the connection
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// true when a read failed because the peer stopped answering pings
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// read the next message from the connection and decode its envelope
+func readEnvelope(ws *websocket.Conn) (Envelope, error) {
+	var env Envelope
+
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		return env, err
+	}
+
+	err = env.UnmarshalBinary(msg)
+	return env, err
+}
+
+// encode the envelope and write it to the connection as a single binary message
+func writeEnvelope(ws *websocket.Conn, env Envelope) error {
+	msg, err := env.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteMessage(websocket.BinaryMessage, msg)
+}
+
+// host side of the handshake: wait for the client hello and answer with a welcome, or with a reject explaining why the client cant join.
+// admit may turn the client away after its hello, e.g. when its id is taken. Returns the client id the connection is
+// allowed to send as, and what it said about itself
+func acceptHello(ws *websocket.Conn, welcome Welcome, admit func(id uint32, hello Hello) error) (uint32, Hello, error) {
+	var hello Hello
+
+	ws.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer ws.SetReadDeadline(time.Time{})
+
+	env, err := readEnvelope(ws)
+	if err != nil {
+		rejectClient(ws, "malformed hello")
+		return 0, hello, fmt.Errorf("reading hello: %w", err)
+	}
+
+	if env.Version != ProtocolVersion {
+		err := versionMismatchError(env.Version, ProtocolVersion)
+		rejectClient(ws, err.Error())
+		return 0, hello, err
+	}
+
+	if env.Type != MsgHello {
+		rejectClient(ws, "expected hello")
+		return 0, hello, fmt.Errorf("expected hello, got %s", env.Type)
+	}
+
+	if len(env.Payload) > 0 {
+		if err := json.Unmarshal(env.Payload, &hello); err != nil {
+			rejectClient(ws, "malformed hello")
+			return 0, hello, fmt.Errorf("reading hello: %w", err)
+		}
+	}
+
+	if err := admit(env.Sender, hello); err != nil {
+		rejectClient(ws, err.Error())
+		return 0, hello, err
+	}
+
+	payload, err := json.Marshal(welcome)
+	if err != nil {
+		return 0, hello, fmt.Errorf("encoding welcome: %w", err)
+	}
+
+	if err := writeEnvelope(ws, NewEnvelope(MsgWelcome, 0, payload)); err != nil {
+		return 0, hello, fmt.Errorf("writing welcome: %w", err)
+	}
+
+	return env.Sender, hello, nil
+}
+
+// tell the client why it is being turned away, then close the connection with the same reason
+func rejectClient(ws *websocket.Conn, reason string) {
+	writeEnvelope(ws, NewEnvelope(MsgReject, 0, []byte(reason)))
+	ws.WriteControl(websocket.CloseMessage, closeFrame(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+}
+
+// payload of a close control frame. Reasons are limited to 123 bytes
+func closeFrame(code int, reason string) []byte {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	return websocket.FormatCloseMessage(code, reason)
+}
+
+// client side of the handshake: send our hello and wait for the host to welcome or reject us
+func sendHello(ws *websocket.Conn, clientID uint32, hello Hello) (Welcome, error) {
+	var welcome Welcome
+
+	payload, err := json.Marshal(hello)
+	if err != nil {
+		return welcome, fmt.Errorf("encoding hello: %w", err)
+	}
+
+	if err := writeEnvelope(ws, NewEnvelope(MsgHello, clientID, payload)); err != nil {
+		return welcome, fmt.Errorf("writing hello: %w", err)
+	}
+
+	ws.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer ws.SetReadDeadline(time.Time{})
+
+	reply, err := readEnvelope(ws)
+	if err != nil {
+		return welcome, fmt.Errorf("waiting for host reply: %w", err)
+	}
+
+	// a reject is checked before the version so a newer host can still explain why it turned us away
+	if reply.Type == MsgReject {
+		return welcome, &refusedError{reason: string(reply.Payload)}
+	}
+
+	if reply.Version != ProtocolVersion {
+		return welcome, &refusedError{reason: versionMismatchError(ProtocolVersion, reply.Version).Error()}
+	}
+
+	if reply.Type != MsgWelcome {
+		return welcome, fmt.Errorf("expected welcome, got %s", reply.Type)
+	}
+
+	if err := json.Unmarshal(reply.Payload, &welcome); err != nil {
+		return welcome, fmt.Errorf("reading welcome: %w", err)
+	}
+
+	return welcome, nil
+}
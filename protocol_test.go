@@ -0,0 +1,104 @@
+package main
+
+import "testing"
+
+func TestElectSuccessor(t *testing.T) {
+	alice := Participant{ID: 30, Addr: "10.0.0.3:8000"}
+	bob := Participant{ID: 10, Addr: "10.0.0.1:8000"}
+	carol := Participant{ID: 20, Addr: "10.0.0.2:8000"}
+	local := Participant{ID: 5} // no address, it cant serve the room
+
+	tests := []struct {
+		name         string
+		participants []Participant
+		gone         map[uint32]bool
+		want         uint32
+		ok           bool
+	}{
+		{"empty room", nil, nil, 0, false},
+		{"lowest id wins", []Participant{alice, bob, carol}, nil, 10, true},
+		{"order doesnt matter", []Participant{carol, alice, bob}, nil, 10, true},
+		{"gone are skipped", []Participant{alice, bob, carol}, map[uint32]bool{10: true}, 20, true},
+		{"everyone gone", []Participant{alice, bob}, map[uint32]bool{10: true, 30: true}, 0, false},
+		{"no address cant take over", []Participant{local, alice}, nil, 30, true},
+		{"only clients without address", []Participant{local}, nil, 0, false},
+		{"duplicate ids agree", []Participant{carol, {ID: 20, Addr: "10.0.0.9:8000"}}, nil, 20, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := electSuccessor(tt.participants, tt.gone)
+			if ok != tt.ok || got.ID != tt.want {
+				t.Errorf("electSuccessor() = %d, %v, want %d, %v", got.ID, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestElectSuccessorTieKeepsFirst(t *testing.T) {
+	first := Participant{ID: 7, Addr: "10.0.0.1:8000"}
+	second := Participant{ID: 7, Addr: "10.0.0.2:8000"}
+
+	got, _ := electSuccessor([]Participant{first, second}, nil)
+	if got != first {
+		t.Errorf("electSuccessor() = %+v, want the first of the tied participants %+v", got, first)
+	}
+}
+
+func TestElectSuccessorOldestWins(t *testing.T) {
+	oldest := Participant{ID: 30, Joined: 100, Addr: "10.0.0.3:8000"}
+	newcomer := Participant{ID: 10, Joined: 200, Addr: "10.0.0.1:8000"}
+
+	got, _ := electSuccessor([]Participant{newcomer, oldest}, nil)
+	if got.ID != oldest.ID {
+		t.Errorf("electSuccessor() = %d, want the participant that joined first %d", got.ID, oldest.ID)
+	}
+}
+
+// what the reconnect loop ends up with: a successor that never answers is marked gone and the next one is elected
+func electReachable(participants []Participant, host uint32, reachable map[uint32]bool) (uint32, bool) {
+	gone := map[uint32]bool{host: true}
+	for {
+		p, ok := electSuccessor(participants, gone)
+		if !ok || reachable[p.ID] {
+			return p.ID, ok
+		}
+		gone[p.ID] = true
+	}
+}
+
+func TestElectSuccessorDifferentSnapshots(t *testing.T) {
+	// the host vanished right after someone joined and someone else left, and not every client heard about it
+	host := Participant{ID: 1, Joined: 100, Addr: "10.0.0.1:8000"}
+	leaver := Participant{ID: 2, Joined: 200, Addr: "10.0.0.2:8000"}
+	carol := Participant{ID: 30, Joined: 300, Addr: "10.0.0.3:8000"}
+	dave := Participant{ID: 40, Joined: 400, Addr: "10.0.0.4:8000"}
+	newcomer := Participant{ID: 3, Joined: 500, Addr: "10.0.0.5:8000"}
+
+	// still in the room, the leaver and the host cant be reached anymore
+	reachable := map[uint32]bool{carol.ID: true, dave.ID: true, newcomer.ID: true}
+
+	tests := []struct {
+		name string
+		a, b []Participant
+	}{
+		{"same list", []Participant{host, carol, dave}, []Participant{host, carol, dave}},
+		{"one missed a join", []Participant{host, carol, dave, newcomer}, []Participant{host, carol, dave}},
+		{"one missed a leave", []Participant{host, carol, dave}, []Participant{host, leaver, carol, dave}},
+		{"each missed something", []Participant{host, carol, dave, newcomer}, []Participant{host, leaver, carol, dave}},
+		{"different order", []Participant{dave, newcomer, carol, host}, []Participant{carol, host, dave}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := electReachable(tt.a, host.ID, reachable)
+			b, okB := electReachable(tt.b, host.ID, reachable)
+			if !okA || !okB || a != b {
+				t.Errorf("clients elected %d (%v) and %d (%v), want the same successor", a, okA, b, okB)
+			}
+			if a != carol.ID {
+				t.Errorf("elected %d, want %d who has been in the room the longest", a, carol.ID)
+			}
+		})
+	}
+}
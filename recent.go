@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// how many servers joined by address are remembered
+const maxRecentServers = 5
+
+// file listing the servers recently joined by address, next to the config file
+func recentServersPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "picto-chat", "recent.json")
+}
+
+// addresses recently joined, most recent first. A missing or unreadable file is an empty list
+func loadRecentServers() []string {
+	path := recentServersPath()
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("failed to read recent servers: %v\n", err)
+		return nil
+	}
+
+	var servers []string
+	if err := json.Unmarshal(data, &servers); err != nil {
+		fmt.Printf("failed to read recent servers %s: %v\n", path, err)
+		return nil
+	}
+
+	if len(servers) > maxRecentServers {
+		servers = servers[:maxRecentServers]
+	}
+	return servers
+}
+
+func saveRecentServers(servers []string) error {
+	path := recentServersPath()
+	if path == "" {
+		return errors.New("no config directory to save recent servers in")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// move addr to the front of the list, dropping the oldest entries past maxRecentServers
+func addRecentServer(servers []string, addr string) []string {
+	recent := []string{addr}
+	for _, s := range servers {
+		if s != addr && len(recent) < maxRecentServers {
+			recent = append(recent, s)
+		}
+	}
+	return recent
+}
+
+// remember a server we managed to reach by address, for the next session
+func (a *App) rememberServer(addr string) {
+	a.mu.Lock()
+	a.recentServers = addRecentServer(a.recentServers, addr)
+	servers := a.recentServers
+	a.mu.Unlock()
+
+	if err := saveRecentServers(servers); err != nil {
+		fmt.Printf("failed to save recent servers: %v\n", err)
+	}
+}
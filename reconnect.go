@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/gorilla/websocket"
+)
+
+// wait before the first redial after a dropped connection, doubled after every failed attempt up to reconnectMaxDelay
+const (
+	reconnectMinDelay = 500 * time.Millisecond
+	reconnectMaxDelay = 10 * time.Second
+)
+
+// how long the host may stay unreachable before the room fails over to a successor. A successor gets twice as long to
+// answer, it only starts serving the room once it gave up on the host itself
+const failoverAfter = 5 * time.Second
+
+// dial a room and say hello. Password is only sent when not empty, rejoin is the secret from the welcome of the
+// connection we are replacing, empty on a first join
+func (a *App) dialRoom(ctx context.Context, roomAddr string, password string, rejoin string) (*websocket.Conn, Welcome, error) {
+	header := http.Header{}
+	if password != "" {
+		header.Set(passwordHeader, password)
+	}
+
+	// the host token only goes to the room we host
+	a.mu.RLock()
+	if a.isRoomHost && a.hostToken != "" {
+		header.Set(hostTokenHeader, a.hostToken)
+	}
+	a.mu.RUnlock()
+
+	c, resp, err := websocket.DefaultDialer.DialContext(ctx, roomAddr, header)
+	if err != nil {
+		return nil, Welcome{}, dialError(resp, err)
+	}
+
+	// introduce ourselves, the host turns away clients speaking another protocol version
+	a.mu.RLock()
+	hello := Hello{Name: a.nickname, Port: a.config.Port, Rejoin: rejoin}
+	a.mu.RUnlock()
+
+	welcome, err := sendHello(c, a.clientID, hello)
+	if err != nil {
+		c.Close()
+		return nil, Welcome{}, err
+	}
+
+	return c, welcome, nil
+}
+
+// make c the room connection, unless the user left the room while we were dialing
+func (a *App) setConn(ctx context.Context, c *websocket.Conn, welcome Welcome) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	a.ws = c
+	a.isServerBooted = true
+	a.joinErr = ""
+	a.roomRules = welcome
+	a.reconnecting = false
+	return true
+}
+
+// read from the room until we leave it. A dropped connection is redialed, the host sends its canvas again on every
+// join so we are back in sync as soon as the snapshot arrives. When the host is gone the room moves to a successor
+func (a *App) superviseConn(ctx context.Context, c *websocket.Conn, roomAddr string, password string) {
+	for {
+		// cancelling ctx hangs up, whoever cancelled it doesnt have to know our connection
+		conn := c
+		stopHangup := context.AfterFunc(ctx, func() { conn.Close() })
+		stopPings := keepAlive(c)
+		stopSender := a.startSender(c)
+		err := a.readRoom(c)
+		stopSender()
+		stopPings()
+		stopHangup()
+		c.Close()
+
+		// closed on purpose by LeaveRoom
+		if ctx.Err() != nil {
+			return
+		}
+
+		// the host ended the room on purpose, there is nothing to reconnect to
+		if reason, ok := roomClosed(err); ok {
+			if reason == "" {
+				reason = "no reason given"
+			}
+			fmt.Printf("room closed by the host: %s\n", reason)
+
+			a.mu.Lock()
+			a.ws = nil
+			a.roomEnded = reason
+			a.mu.Unlock()
+			return
+		}
+
+		// a host that left on purpose names its successor
+		var handoff *Handoff
+		var handedOff *handoffError
+		if errors.As(err, &handedOff) {
+			fmt.Println(err)
+			handoff = &handedOff.handoff
+		} else if isTimeout(err) {
+			fmt.Printf("lost connection to room, no answer to pings for %s\n", pongWait)
+		} else {
+			fmt.Printf("lost connection to room: %v\n", err)
+		}
+
+		a.mu.Lock()
+		a.ws = nil
+		a.reconnecting = true
+		a.mu.Unlock()
+
+		var welcome Welcome
+		lastAddr := roomAddr
+		c, welcome, roomAddr, err = a.reconnect(ctx, roomAddr, password, handoff)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			a.mu.Lock()
+			var takeover *takeoverError
+			if errors.As(err, &takeover) {
+				// we host the room now, the render loop starts serving it and joins it like the host window does
+				a.takeover = &takeover.handoff
+			} else {
+				// the host is there but wont have us back, e.g. the room was closed or its password changed
+				a.reconnecting = false
+				a.roomLost = err.Error()
+			}
+			a.mu.Unlock()
+			return
+		}
+
+		if !a.setConn(ctx, c, welcome) {
+			c.Close()
+			return
+		}
+		fmt.Println("Reconnected to WebSocket Server")
+
+		if roomAddr != lastAddr {
+			a.mu.Lock()
+			a.currentRoom.URL = roomAddr
+			if u, err := url.Parse(roomAddr); err == nil {
+				a.currentRoom.hostName = u.Hostname()
+			}
+			a.mu.Unlock()
+			a.ShowNotice("The room moved to a new host")
+		}
+	}
+}
+
+// takeoverError is returned by reconnect when we are the successor and should host the room ourselves
+type takeoverError struct {
+	handoff Handoff
+}
+
+func (e *takeoverError) Error() string {
+	return "taking over the room as host"
+}
+
+// redial with exponential backoff until it works, the host refuses us or ctx is cancelled. handoff is set when the
+// host left and named its successor. Otherwise once the host has been unreachable for failoverAfter, the room fails
+// over to the next successor in line, the same one everyone else in the room picks. Returns the address we got back
+// in through, or a takeoverError when it is our turn to host
+func (a *App) reconnect(ctx context.Context, roomAddr string, password string, handoff *Handoff) (*websocket.Conn, Welcome, string, error) {
+	// the participant list as of the drop, nothing updates it while we are disconnected. Clients whose lists differ
+	// by a late join or leave still end up with the same successor, see electSuccessor
+	a.mu.RLock()
+	participants := a.participants
+	target := a.roomRules.HostID
+	rejoin := a.roomRules.Rejoin
+	a.mu.RUnlock()
+
+	gone := map[uint32]bool{}
+	failAt := time.Now().Add(failoverAfter)
+
+	// move on to a successor, or take the room over when it is us
+	successor := func(p Participant, h Handoff) error {
+		if p.ID == a.clientID {
+			return &takeoverError{handoff: h}
+		}
+
+		fmt.Printf("moving to the new host, client %d at %s\n", p.ID, p.Addr)
+		roomAddr = successorURL(roomAddr, p)
+		target = p.ID
+		rejoin = "" // only the host that gave it to us knows it
+		failAt = time.Now().Add(2 * failoverAfter)
+		return nil
+	}
+
+	if handoff != nil {
+		gone[target] = true
+		if err := successor(handoff.Successor, *handoff); err != nil {
+			return nil, Welcome{}, roomAddr, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		delay := reconnectDelay(attempt)
+
+		a.mu.Lock()
+		a.reconnectAttempt = attempt
+		a.nextReconnect = time.Now().Add(delay)
+		a.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, Welcome{}, roomAddr, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		c, welcome, err := a.dialRoom(ctx, roomAddr, password, rejoin)
+		if err == nil {
+			return c, welcome, roomAddr, nil
+		}
+		fmt.Printf("reconnect attempt %d failed: %v\n", attempt, err)
+
+		if isRefused(err) {
+			return nil, Welcome{}, roomAddr, err
+		}
+
+		if time.Now().Before(failAt) {
+			continue
+		}
+
+		// whoever we were trying looks gone for good. When nobody can take over we keep waiting for it
+		gone[target] = true
+		next, ok := electSuccessor(participants, gone)
+		if !ok {
+			failAt = time.Now().Add(failoverAfter)
+			continue
+		}
+
+		if err := successor(next, a.electedHandoff(next, roomAddr, password)); err != nil {
+			return nil, Welcome{}, roomAddr, err
+		}
+		attempt = 0
+	}
+}
+
+// what we know about the room to host it after an election, there was no handoff from the host to tell us
+func (a *App) electedHandoff(successor Participant, roomAddr string, password string) Handoff {
+	// everyone else dials the successor on the path we knew the room by
+	var path string
+	if u, err := url.Parse(roomAddr); err == nil {
+		path = u.Path
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return Handoff{
+		Successor:     successor,
+		Path:          path,
+		Name:          a.currentRoom.Name,
+		Topic:         a.currentRoom.Topic,
+		Tag:           a.currentRoom.Tag,
+		Password:      password,
+		HostOnlyClear: a.roomRules.HostOnlyClear,
+	}
+}
+
+// address of the room on its successor, which serves it under the same path
+func successorURL(roomAddr string, successor Participant) string {
+	u, err := url.Parse(roomAddr)
+	if err != nil {
+		return roomAddr
+	}
+	u.Host = successor.Addr
+	return u.String()
+}
+
+// wait before the given attempt (starting at 1). Jittered so a room full of clients dropped at once doesnt redial in
+// lockstep
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectMaxDelay
+	if attempt < 16 {
+		delay = min(reconnectMinDelay<<(attempt-1), reconnectMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// LeaveRoom stops the connection supervisor and closes the room connection
+func (a *App) LeaveRoom() {
+	a.mu.Lock()
+	if a.leaveRoom != nil {
+		a.leaveRoom()
+		a.leaveRoom = nil
+	}
+
+	ws := a.ws
+	a.ws = nil
+	a.currentRoom = Room{}
+	a.isServerBooted = false
+	a.reconnecting = false
+	a.roomLost = ""
+	a.roomEnded = ""
+	a.takeover = nil
+	a.joining = false
+	a.outbox = nil
+	a.participants = nil
+	a.mu.Unlock()
+
+	if ws != nil {
+		fmt.Println("Closing server connection...")
+		ws.Close()
+	}
+}
+
+// go back to the room options when the supervisor gave up on the room, explaining why, or start hosting it when the
+// supervisor found it is our turn
+func (a *App) UpdateConnection() {
+	a.mu.RLock()
+	lost := a.roomLost
+	takeover := a.takeover
+	a.mu.RUnlock()
+
+	if takeover != nil {
+		a.TakeOverRoom(*takeover)
+		return
+	}
+
+	if lost == "" {
+		return
+	}
+
+	if a.isRoomHost {
+		a.CloseHostedRoom()
+	}
+	a.LeaveRoom()
+
+	a.joinErr = fmt.Sprintf("Lost the room: %s", lost)
+	a.currentAppState = AppStateRoomConfig
+}
+
+// TakeOverRoom serves the room we are in after its host handed it to us, or vanished and we were elected, then joins
+// it like MakeRoom. Everyone else in the room is already redialing us
+func (a *App) TakeOverRoom(handoff Handoff) {
+	a.mu.Lock()
+	a.takeover = nil
+	if a.leaveRoom != nil {
+		a.leaveRoom()
+		a.leaveRoom = nil
+	}
+
+	// a handoff carries the host canvas, after an election ours is the best there is
+	data := handoff.Canvas
+	var err error
+	if data == nil {
+		data, err = a.canvas.MarshalBinary()
+	}
+	a.mu.Unlock()
+
+	canvas := NewCanvas()
+	if err == nil {
+		err = canvas.UnmarshalBinary(data)
+	}
+
+	if err == nil {
+		err = a.MakeRoom(RoomSettings{
+			Path:          handoff.Path,
+			Name:          handoff.Name,
+			Topic:         handoff.Topic,
+			Tag:           handoff.Tag,
+			Password:      handoff.Password,
+			HostOnlyClear: handoff.HostOnlyClear,
+		}, canvas)
+	}
+
+	if err != nil {
+		fmt.Printf("failed to take over the room: %v\n", err)
+		a.mu.Lock()
+		a.roomLost = fmt.Sprintf("could not take over as host: %v", err)
+		a.mu.Unlock()
+		return
+	}
+
+	fmt.Printf("Took over room %q as host\n", handoff.Name)
+	a.ShowNotice("The host left, you host the room now")
+}
+
+// banner on top of the canvas while the connection is down. Drawing still works, the points are sent once we are back
+func (a *App) DrawConnectionBanner() {
+	a.mu.RLock()
+	reconnecting := a.reconnecting
+	attempt := a.reconnectAttempt
+	next := time.Until(a.nextReconnect)
+	a.mu.RUnlock()
+
+	if !reconnecting {
+		return
+	}
+
+	text := fmt.Sprintf("Reconnecting... attempt %d", attempt)
+	if next > 0 {
+		text = fmt.Sprintf("Connection lost, reconnecting in %.0fs (attempt %d)", next.Seconds()+0.5, attempt)
+	}
+
+	banner := rl.NewRectangle((screenWidth/2)-375, 100, float32(750), float32(50))
+	rl.DrawRectangleRounded(banner, float32(0.5), int32(0), rl.Orange)
+	drawTextCentered(a.font.Italic, text, int(banner.Y)+12, 25, rl.Black)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectDelayBounds(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, reconnectMinDelay / 2, reconnectMinDelay},
+		{2, reconnectMinDelay, 2 * reconnectMinDelay},
+		{3, 2 * reconnectMinDelay, 4 * reconnectMinDelay},
+		{5, 8 * reconnectMinDelay, reconnectMaxDelay},
+		{10, reconnectMaxDelay / 2, reconnectMaxDelay},
+		{16, reconnectMaxDelay / 2, reconnectMaxDelay},
+		{1000, reconnectMaxDelay / 2, reconnectMaxDelay},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			if got := reconnectDelay(tt.attempt); got < tt.min || got >= tt.max {
+				t.Fatalf("reconnectDelay(%d) = %s, want in [%s, %s)", tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestSuccessorURL(t *testing.T) {
+	got := successorURL("ws://10.0.0.1:8000/ws/doodles-2", Participant{ID: 3, Addr: "10.0.0.7:9000"})
+	if want := "ws://10.0.0.7:9000/ws/doodles-2"; got != want {
+		t.Errorf("successorURL() = %q, want %q", got, want)
+	}
+}
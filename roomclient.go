@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// messages waiting for a client before it is considered too slow to keep up and disconnected. Dropping single
+// messages would leave holes in its canvas, a disconnected client reconnects and gets a fresh snapshot instead
+const sendQueueSize = 256
+
+// how long a closed client has to answer our close frame before the connection is dropped
+const closeGrace = time.Second
+
+// roomClient is one connection to a hosted room. Everything sent to it goes through its queue and is written by its
+// own goroutine, so a slow receiver never stalls the room and the connection only ever has one writer
+type roomClient struct {
+	ws          *websocket.Conn
+	participant Participant
+	rejoin      string // secret the client got in its welcome, a reconnect presenting it may replace this connection
+
+	send      chan []byte
+	closing   chan []byte   // close frame to write once the queue is flushed
+	done      chan struct{} // closed by stop
+	finished  chan struct{} // closed when the writer returns
+	stopOnce  sync.Once
+	closeOnce sync.Once
+}
+
+func newRoomClient(ws *websocket.Conn, p Participant) *roomClient {
+	c := &roomClient{
+		ws:          ws,
+		participant: p,
+		send:        make(chan []byte, sendQueueSize),
+		closing:     make(chan []byte, 1),
+		done:        make(chan struct{}),
+		finished:    make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// queue an encoded envelope for the client. Returns false when the queue is full, the caller should disconnect it
+func (c *roomClient) enqueue(msg []byte) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// write queued messages until the client is stopped, closed or a write fails
+func (c *roomClient) writeLoop() {
+	defer close(c.finished)
+
+	for {
+		select {
+		case <-c.done:
+			return
+
+		case msg := <-c.send:
+			if !c.write(msg) {
+				return
+			}
+
+		case frame := <-c.closing:
+			// deliver what was queued before the close, then say goodbye
+			for flushed := false; !flushed; {
+				select {
+				case msg := <-c.send:
+					if !c.write(msg) {
+						return
+					}
+				default:
+					flushed = true
+				}
+			}
+
+			c.ws.WriteControl(websocket.CloseMessage, frame, time.Now().Add(writeWait))
+
+			// the reader sees the peer answer the close and leaves the room, hang up if it doesnt
+			time.AfterFunc(closeGrace, c.stop)
+			return
+		}
+	}
+}
+
+func (c *roomClient) write(msg []byte) bool {
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+		fmt.Printf("error writing to client %d: %v\n", c.participant.ID, err)
+		c.ws.Close()
+		return false
+	}
+	return true
+}
+
+// disconnect gracefully: flush the queue, then send a close frame with the code and reason
+func (c *roomClient) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closing <- closeFrame(code, reason)
+	})
+}
+
+// stop the writer and close the connection, its reader then removes it from the room
+func (c *roomClient) stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
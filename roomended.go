@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// reason the host gave for ending the room we are in, empty while the room is open
+func (a *App) endedRoom() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.roomEnded
+}
+
+// answers to the 'Host ended the room' prompt: [S] saves the canvas before it is gone, [Enter] goes back to the rooms
+func (a *App) UpdateRoomEnded() {
+	if rl.IsKeyPressed(rl.KeyS) {
+		path, err := a.SaveCanvas()
+		if err != nil {
+			a.endedRoomSave = fmt.Sprintf("Save failed: %v", err)
+		} else {
+			a.endedRoomSave = fmt.Sprintf("Saved %s", path)
+		}
+	}
+
+	if rl.IsKeyPressed(rl.KeyEnter) || rl.IsKeyPressed(rl.KeyKpEnter) {
+		if a.isRoomHost {
+			a.CloseHostedRoom()
+		}
+		a.LeaveRoom()
+		a.ResetCanvas()
+
+		a.confirmingClear = false
+		a.endedRoomSave = ""
+		a.OpenRoomSelect()
+	}
+}
+
+// prompt drawn on top of the canvas once the host ended the room
+func (a *App) DrawRoomEnded() {
+	insertRec := rl.NewRectangle((screenWidth/2)-450, (screenHeight/2)-150, float32(900), float32(300))
+	promptContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
+
+	rl.DrawRectangleRounded(insertRec, float32(0.3), int32(0), rl.White)
+	rl.DrawRectangleRounded(promptContainer, float32(0.3), int32(0), rl.Black)
+
+	drawTextCentered(a.font.Regular, "Host ended the room", int(insertRec.Y)+40, 40, rl.White)
+	drawTextCentered(a.font.Italic, a.endedRoom(), int(insertRec.Y)+100, 25, rl.Gray)
+
+	if a.endedRoomSave != "" {
+		drawTextCentered(a.font.Italic, a.endedRoomSave, int(insertRec.Y)+150, 25, rl.Green)
+	}
+
+	drawTextCentered(a.font.Italic, "[S] Save canvas     [Enter] Back to rooms", int(insertRec.Y)+215, 30, rl.White)
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// password prompt drawn on top of the room list
+func passwordPromptRect() rl.Rectangle {
+	return rl.NewRectangle((screenWidth/2)-350, (screenHeight/2)-170, float32(700), float32(340))
+}
+
+func passwordCancelRect() rl.Rectangle {
+	rec := passwordPromptRect()
+	return rl.NewRectangle(rec.X+120, rec.Y+rec.Height-100, float32(200), float32(70))
+}
+
+func passwordJoinRect() rl.Rectangle {
+	rec := passwordPromptRect()
+	return rl.NewRectangle(rec.X+rec.Width-320, rec.Y+rec.Height-100, float32(200), float32(70))
+}
+
+// OpenRoomSelect goes to the list of rooms and starts looking for them
+func (a *App) OpenRoomSelect() {
+	a.isRoomHost = false
+	a.addressForm = newAddressForm()
+	a.recentServers = loadRecentServers()
+	go func() {
+		a.MDNSLookup()
+		a.lastMDNSQuery = time.Now()
+	}()
+	a.currentAppState = AppStateRoomSelect
+}
+
+// JoinRoom connects to a room picked from the list, locked rooms ask for their password first
+func (a *App) JoinRoom(room Room) {
+	// a double click or a second room picked while the first is still dialing
+	if a.isJoining() {
+		return
+	}
+	a.joinErr = ""
+
+	if room.Locked {
+		rec := passwordPromptRect()
+		a.pendingRoom = room
+		a.passwordForm = &Form{Inputs: []*TextInput{
+			{Label: "Password for " + room.Name, MaxLen: 32, Masked: true, Rect: rl.NewRectangle(rec.X+50, rec.Y+90, rec.Width-100, float32(60))},
+		}}
+		return
+	}
+
+	a.startJoin(room, "")
+}
+
+func (a *App) isJoining() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.joining
+}
+
+// dial room in the background, further joins are ignored until it is in or failed
+func (a *App) startJoin(room Room, password string) {
+	a.mu.Lock()
+	a.joining = true
+	a.currentRoom = room
+	a.mu.Unlock()
+
+	go a.JoinWsServer(room.URL, password)
+}
+
+func (a *App) DrawPasswordPrompt() {
+	insertRec := passwordPromptRect()
+	promptContainer := rl.NewRectangle(insertRec.X+5, insertRec.Y+5, insertRec.Width-10, insertRec.Height-10)
+
+	rl.DrawRectangleRounded(insertRec, float32(0.3), int32(0), rl.White)
+	rl.DrawRectangleRounded(promptContainer, float32(0.3), int32(0), rl.Black)
+
+	a.passwordForm.Draw(a.font.Italic)
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	drawButton(a.font.BoldItalic, passwordCancelRect(), "Cancel", rl.CheckCollisionPointRec(mouse, passwordCancelRect()))
+	drawButton(a.font.BoldItalic, passwordJoinRect(), "Join", rl.CheckCollisionPointRec(mouse, passwordJoinRect()))
+}
+
+// type the password, [Enter] or 'Join' dials the room with it and 'Cancel' closes the prompt
+func (a *App) UpdatePasswordPrompt() {
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	a.passwordForm.Update(mouse)
+
+	released := rl.IsMouseButtonReleased(rl.MouseButtonLeft)
+
+	if released && rl.CheckCollisionPointRec(mouse, passwordCancelRect()) {
+		a.passwordForm = nil
+		return
+	}
+
+	if rl.IsKeyPressed(rl.KeyEnter) || rl.IsKeyPressed(rl.KeyKpEnter) || (released && rl.CheckCollisionPointRec(mouse, passwordJoinRect())) {
+		password := a.passwordForm.Inputs[0].Value
+		a.passwordForm = nil
+
+		if !a.isJoining() {
+			a.startJoin(a.pendingRoom, password)
+		}
+	}
+}
+
+// address field and recent servers under the room list, for networks where mDNS doesnt get through
+func newAddressForm() *Form {
+	return &Form{
+		Inputs: []*TextInput{
+			{Label: "Join by address", Placeholder: "192.168.1.5:8000", MaxLen: 96, Rect: rl.NewRectangle((screenWidth/2)-300, float32(700), float32(600), float32(60))},
+		},
+		Focus: noFocus,
+	}
+}
+
+// clickable recent servers, laid out in one row under the address field
+func (a *App) recentServerRects(servers []string) []rl.Rectangle {
+	rects := make([]rl.Rectangle, len(servers))
+
+	x := float32((screenWidth / 2) - 300 + 120)
+	for i, server := range servers {
+		mes := rl.MeasureTextEx(a.font.Italic, server, 25, 1)
+		rects[i] = rl.NewRectangle(x, float32(790), mes.X, mes.Y)
+		x += mes.X + 30
+	}
+	return rects
+}
+
+func (a *App) DrawAddressInput() {
+	a.addressForm.Draw(a.font.Italic)
+
+	a.mu.RLock()
+	servers := a.recentServers
+	a.mu.RUnlock()
+
+	if len(servers) == 0 {
+		return
+	}
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	rl.DrawTextEx(a.font.Italic, "Recent:", rl.NewVector2((screenWidth/2)-300, 790), 25, 1, rl.Gray)
+	for i, rec := range a.recentServerRects(servers) {
+		color := rl.Gray
+		if rl.CheckCollisionPointRec(mouse, rec) {
+			color = rl.Blue
+		}
+		rl.DrawTextEx(a.font.Italic, servers[i], rl.NewVector2(rec.X, rec.Y), 25, 1, color)
+	}
+}
+
+// type an address and press [Enter], or click a recent server. Clicking anywhere else takes the focus off the field
+func (a *App) UpdateAddressInput() {
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+
+	if rl.IsMouseButtonPressed(rl.MouseButtonLeft) {
+		a.addressForm.Focus = noFocus
+	}
+	a.addressForm.Update(mouse)
+
+	input := a.addressForm.Inputs[0]
+	if a.addressForm.Focused() != nil && (rl.IsKeyPressed(rl.KeyEnter) || rl.IsKeyPressed(rl.KeyKpEnter)) && input.Text() != "" {
+		a.JoinByAddress(input.Text())
+	}
+
+	if rl.IsMouseButtonReleased(rl.MouseButtonLeft) {
+		a.mu.RLock()
+		servers := a.recentServers
+		a.mu.RUnlock()
+
+		for i, rec := range a.recentServerRects(servers) {
+			if rl.CheckCollisionPointRec(mouse, rec) {
+				input.Value = servers[i]
+				a.JoinByAddress(servers[i])
+			}
+		}
+	}
+
+	// a lookup that found the room to join, joined from here so a locked room can open the password prompt
+	a.mu.Lock()
+	room := a.autoJoin
+	a.autoJoin = nil
+	a.mu.Unlock()
+
+	if room != nil {
+		a.JoinRoom(*room)
+	}
+}
+
+// split what was typed into host:port and an optional room path. Accepts host, host:port or a ws:// URL
+func parseRoomAddress(input string, defaultPort int) (host string, path string, err error) {
+	s := strings.TrimSpace(input)
+	if !strings.Contains(s, "://") {
+		s = "ws://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid address %q", input)
+	}
+
+	if u.Scheme != "ws" {
+		return "", "", fmt.Errorf("unsupported scheme %q, use ws://", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("missing host in %q", input)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = strconv.Itoa(defaultPort)
+	}
+
+	path = strings.TrimSuffix(u.Path, "/")
+	if path != "" && !strings.HasPrefix(path, roomPathPrefix) {
+		return "", "", fmt.Errorf("room paths start with %s", roomPathPrefix)
+	}
+
+	return net.JoinHostPort(u.Hostname(), port), path, nil
+}
+
+// ask a host which rooms it serves, host is host:port
+func fetchRooms(host string) ([]Room, error) {
+	client := http.Client{Timeout: handshakeTimeout}
+
+	resp, err := client.Get("http://" + host + roomListPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing rooms: %s", resp.Status)
+	}
+
+	var infos []RoomInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, fmt.Errorf("reading room list: %w", err)
+	}
+
+	rooms := make([]Room, 0, len(infos))
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Path, roomPathPrefix) {
+			continue
+		}
+		rooms = append(rooms, roomAt(host, info))
+	}
+	return rooms, nil
+}
+
+// room served by host (host:port) described by info
+func roomAt(host string, info RoomInfo) Room {
+	addr, portStr, _ := net.SplitHostPort(host)
+	port, _ := strconv.Atoi(portStr)
+
+	name := info.Name
+	if name == "" {
+		name = info.Path
+	}
+
+	return Room{
+		hostName:     addr,
+		Name:         name,
+		Addr:         addr,
+		Port:         port,
+		Path:         info.Path,
+		URL:          fmt.Sprintf("ws://%s%s", host, info.Path),
+		Topic:        info.Topic,
+		Tag:          info.Tag,
+		Proto:        info.Proto,
+		Participants: info.Participants,
+		Locked:       info.Locked,
+	}
+}
+
+// JoinByAddress looks up the rooms of a host typed in by hand. The rooms are added to the list, and joined straight
+// away when the address names one or the host only serves one
+func (a *App) JoinByAddress(input string) {
+	host, path, err := parseRoomAddress(input, a.config.Port)
+	if err != nil {
+		a.joinErr = err.Error()
+		return
+	}
+	a.joinErr = ""
+
+	go func() {
+		rooms, err := fetchRooms(host)
+		if err != nil {
+			if path == "" {
+				a.mu.Lock()
+				a.joinErr = fmt.Sprintf("Could not list rooms on %s: %v", host, err)
+				a.mu.Unlock()
+				return
+			}
+
+			// hosts that dont list their rooms can still be joined with the full room path
+			fmt.Printf("failed to list rooms on %s: %v\n", host, err)
+			rooms = []Room{roomAt(host, RoomInfo{Path: path})}
+		}
+
+		a.rememberServer(host + path)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		a.manualRooms = rooms
+
+		switch {
+		case path != "":
+			for i := range rooms {
+				if rooms[i].Path == path {
+					a.autoJoin = &rooms[i]
+					return
+				}
+			}
+			a.joinErr = fmt.Sprintf("No room %s on %s", path, host)
+
+		case len(rooms) == 1:
+			a.autoJoin = &rooms[0]
+
+		case len(rooms) == 0:
+			a.joinErr = fmt.Sprintf("No rooms on %s", host)
+		}
+	}()
+}
+
+// rooms found over mDNS followed by the ones looked up by address
+func (a *App) roomList() []Room {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	rooms := append([]Room{}, a.availRooms...)
+	for _, manual := range a.manualRooms {
+		if !slices.ContainsFunc(rooms, func(r Room) bool { return r.URL == manual.URL }) {
+			rooms = append(rooms, manual)
+		}
+	}
+	return rooms
+}
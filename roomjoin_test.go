@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParseRoomAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantHost string
+		wantPath string
+		wantErr  bool
+	}{
+		{"192.168.1.20", "192.168.1.20:8000", "", false},
+		{"  192.168.1.20:9000  ", "192.168.1.20:9000", "", false},
+		{"picto.local", "picto.local:8000", "", false},
+		{"ws://192.168.1.20:9000/ws/doodles", "192.168.1.20:9000", "/ws/doodles", false},
+		{"ws://192.168.1.20/ws/doodles/", "192.168.1.20:8000", "/ws/doodles", false},
+		{"[::1]:9000", "[::1]:9000", "", false},
+		{"http://192.168.1.20", "", "", true},
+		{"ws:///ws/doodles", "", "", true},
+		{"", "", "", true},
+		{"192.168.1.20/rooms", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			host, path, err := parseRoomAddress(tt.input, 8000)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRoomAddress(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			}
+			if host != tt.wantHost || path != tt.wantPath {
+				t.Errorf("parseRoomAddress(%q) = %q, %q, want %q, %q", tt.input, host, path, tt.wantHost, tt.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/mdns"
+	"github.com/miekg/dns"
+)
+
+const (
+	roomPathPrefix = "/ws/"   // every room is served under this path prefix, followed by its slug
+	roomListPath   = "/rooms" // JSON list of the rooms served, see RoomInfo
+)
+
+// RoomSettings describe a room when it is made. Name, topic and tag are advertised over mDNS
+type RoomSettings struct {
+	Path          string // exact path to serve the room on, picked from the name when empty
+	Name          string
+	Topic         string
+	Tag           string
+	Password      string // required to join when not empty, checked before the WebSocket upgrade
+	HostID        uint32 // client id of the window that made the room, 0 when nobody hosts it from a window
+	HostToken     string // secret the host window presents when joining, only its connection gets host privileges
+	HostOnlyClear bool
+}
+
+// hostedRoom is one room served by this process. Each room has its own clients and canvas
+type hostedRoom struct {
+	settings RoomSettings
+	path     string
+
+	hub *Hub // connections and the authoritative canvas
+
+	mdns *mdns.Server
+	zone *roomZone
+}
+
+// roomZone answers mDNS queries for a room. It wraps the service so the TXT record can change while the room is
+// advertised (the participant count changes on every join and leave)
+type roomZone struct {
+	mu      sync.Mutex
+	service *mdns.MDNSService
+}
+
+func (z *roomZone) Records(q dns.Question) []dns.RR {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.service.Records(q)
+}
+
+func (z *roomZone) SetTXT(info []string) {
+	z.mu.Lock()
+	z.service.TXT = info
+	z.mu.Unlock()
+}
+
+// RoomServer serves every room hosted by this process under one http.Server, each room on its own path
+type RoomServer struct {
+	cfg    Config
+	server *http.Server
+
+	mu    sync.Mutex
+	rooms map[string]*hostedRoom // keyed by path
+}
+
+// NewRoomServer starts listening on the configured address. Listening happens before returning so errors such as the
+// port already being in use are reported to the caller
+func NewRoomServer(cfg Config) (*RoomServer, error) {
+	rs := &RoomServer{cfg: cfg, rooms: make(map[string]*hostedRoom)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(roomPathPrefix, rs.handleRoom)
+	mux.HandleFunc(roomListPath, rs.handleRoomList)
+
+	rs.server = &http.Server{
+		Addr:    cfg.ListenAddr(),
+		Handler: mux,
+	}
+
+	ln, err := net.Listen("tcp", rs.server.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Started WebSocket Server on %s\n", rs.server.Addr)
+	go func() {
+		if err := rs.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("server error: %v\n", err)
+		}
+	}()
+
+	return rs, nil
+}
+
+// list the rooms served here as JSON, for clients that cant use mDNS and join by address
+func (rs *RoomServer) handleRoomList(w http.ResponseWriter, r *http.Request) {
+	rs.mu.Lock()
+	rooms := make([]*hostedRoom, 0, len(rs.rooms))
+	for _, room := range rs.rooms {
+		rooms = append(rooms, room)
+	}
+	rs.mu.Unlock()
+
+	infos := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		infos = append(infos, room.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Path < infos[j].Path })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// route a connection to the room matching the request path
+func (rs *RoomServer) handleRoom(w http.ResponseWriter, r *http.Request) {
+	rs.mu.Lock()
+	room, ok := rs.rooms[r.URL.Path]
+	rs.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "no such room", http.StatusNotFound)
+		return
+	}
+
+	room.HandleConnections(w, r)
+}
+
+// AddRoom starts serving a new room and advertises it over mDNS. The connection presenting the settings host token may
+// open canvases (and clear when HostOnlyClear is set), canvas is the starting drawing and may be nil
+func (rs *RoomServer) AddRoom(settings RoomSettings, canvas *Canvas) (*hostedRoom, error) {
+	room := &hostedRoom{
+		settings: settings,
+		hub:      NewHub(canvas),
+	}
+	room.hub.OnChange = room.updateTXT
+
+	// give the room a unique path, rooms with the same name get a numbered suffix. A room taken over from another host
+	// keeps its path, its clients are already dialing it
+	rs.mu.Lock()
+	if settings.Path != "" {
+		if !strings.HasPrefix(settings.Path, roomPathPrefix) || rs.rooms[settings.Path] != nil {
+			rs.mu.Unlock()
+			return nil, fmt.Errorf("cant serve a room on %s", settings.Path)
+		}
+		room.path = settings.Path
+	} else {
+		slug := roomSlug(settings.Name)
+		room.path = roomPathPrefix + slug
+		for i := 2; rs.rooms[room.path] != nil; i++ {
+			room.path = fmt.Sprintf("%s%s-%d", roomPathPrefix, slug, i)
+		}
+	}
+	rs.rooms[room.path] = room
+	rs.mu.Unlock()
+
+	if err := room.advertise(rs.cfg); err != nil {
+		rs.RemoveRoom(room, "")
+		return nil, err
+	}
+
+	fmt.Printf("Hosting room %q on %s\n", settings.Name, room.path)
+	return room, nil
+}
+
+// RemoveRoom stops advertising the room and disconnects its clients, telling them why
+func (rs *RoomServer) RemoveRoom(room *hostedRoom, reason string) {
+	rs.mu.Lock()
+	delete(rs.rooms, room.path)
+	rs.mu.Unlock()
+
+	room.Close(reason)
+}
+
+// HandOffRoom passes the room on to one of its clients, see Hub.Handoff, and stops serving it. A room with nobody left
+// to take over is closed like RemoveRoom does
+func (rs *RoomServer) HandOffRoom(room *hostedRoom) {
+	rs.mu.Lock()
+	delete(rs.rooms, room.path)
+	rs.mu.Unlock()
+
+	room.stopAdvertising()
+
+	handoff := Handoff{
+		Path:          room.path,
+		Name:          room.settings.Name,
+		Topic:         room.settings.Topic,
+		Tag:           room.settings.Tag,
+		Password:      room.settings.Password,
+		HostOnlyClear: room.settings.HostOnlyClear,
+	}
+	if !room.hub.Handoff(handoff, room.settings.HostID) {
+		room.hub.Close("host closed the room")
+	}
+}
+
+// number of rooms still being served
+func (rs *RoomServer) RoomCount() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return len(rs.rooms)
+}
+
+// Shutdown closes every room and stops the http server
+func (rs *RoomServer) Shutdown(ctx context.Context) error {
+	rs.mu.Lock()
+	rooms := make([]*hostedRoom, 0, len(rs.rooms))
+	for _, room := range rs.rooms {
+		rooms = append(rooms, room)
+	}
+	rs.rooms = make(map[string]*hostedRoom)
+	rs.mu.Unlock()
+
+	for _, room := range rooms {
+		room.Close("server shutting down")
+	}
+
+	fmt.Println("Server Shutdown...")
+	return rs.server.Shutdown(ctx)
+}
+
+// RoomInfo describes a room to people looking for one. It is advertised in the mDNS TXT record and listed on
+// roomListPath for clients joining by address
+type RoomInfo struct {
+	Name         string `json:"name"`
+	Topic        string `json:"topic"`
+	Tag          string `json:"tag"`
+	Path         string `json:"path"`
+	Proto        int    `json:"proto"`
+	Participants int    `json:"count"`
+	Locked       bool   `json:"locked"`
+}
+
+func (room *hostedRoom) info() RoomInfo {
+	return RoomInfo{
+		Name:         room.settings.Name,
+		Topic:        room.settings.Topic,
+		Tag:          room.settings.Tag,
+		Path:         room.path,
+		Proto:        int(ProtocolVersion),
+		Participants: room.ClientCount(),
+		Locked:       room.settings.Password != "",
+	}
+}
+
+// key=value TXT record fields describing the room: what it is about, where to dial and whether we can talk to it
+func (room *hostedRoom) txt() []string {
+	info := room.info()
+	return []string{
+		"name=" + info.Name,
+		"topic=" + info.Topic,
+		"tag=" + info.Tag,
+		"path=" + info.Path,
+		"proto=" + strconv.Itoa(info.Proto),
+		"count=" + strconv.Itoa(info.Participants),
+		"locked=" + strconv.FormatBool(info.Locked),
+	}
+}
+
+// republish the TXT record, called whenever a client joins or leaves
+func (room *hostedRoom) updateTXT() {
+	if room.zone != nil {
+		room.zone.SetTXT(room.txt())
+	}
+}
+
+// advertise the room over mDNS. The instance name includes the path so several rooms on one machine dont collide, the
+// TXT record carries the description and the path to dial
+func (room *hostedRoom) advertise(cfg Config) error {
+	hostName, _ := os.Hostname()
+
+	// advertise only the address we listen on when bound to a single interface
+	var ips []net.IP
+	if ip := cfg.bindIP(); ip != nil {
+		ips = []net.IP{ip}
+	}
+
+	instance := fmt.Sprintf("%s-%s", strings.TrimPrefix(room.path, roomPathPrefix), roomSlug(hostName))
+
+	service, err := mdns.NewMDNSService(instance, "_pictochat._tcp", "", "", cfg.Port, ips, room.txt())
+	if err != nil {
+		return fmt.Errorf("creating mDNS service: %w", err)
+	}
+	room.zone = &roomZone{service: service}
+
+	fmt.Println("Starting MDNS Server...")
+	room.mdns, err = mdns.NewServer(&mdns.Config{Zone: room.zone})
+	if err != nil {
+		return fmt.Errorf("starting mDNS server: %w", err)
+	}
+
+	return nil
+}
+
+// Close stops advertising the room and disconnects every client with a close frame carrying the reason
+func (room *hostedRoom) Close(reason string) {
+	room.stopAdvertising()
+	room.hub.Close(reason)
+}
+
+func (room *hostedRoom) stopAdvertising() {
+	if room.mdns != nil {
+		room.mdns.Shutdown()
+		fmt.Println("MDNS Server Shutdown...")
+	}
+}
+
+// true when the room is open or the password matches
+func (room *hostedRoom) checkPassword(password string) bool {
+	if room.settings.Password == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(room.settings.Password)) == 1
+}
+
+// number of clients connected to the room
+func (room *hostedRoom) ClientCount() int {
+	return room.hub.Count()
+}
+
+// lower case letters, digits and dashes only, so a room name can be used in a URL path and an mDNS instance name
+func roomSlug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteRune('-')
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		slug = "room"
+	}
+	return slug
+}
+
+// split key=value TXT record fields into a map, fields without '=' are ignored
+func parseTXT(fields []string) map[string]string {
+	txt := make(map[string]string)
+	for _, f := range fields {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			txt[k] = v
+		}
+	}
+	return txt
+}
+
+// HandleConnections runs for the lifetime of one client connection: handshake, snapshot, then relaying its messages to
+// the rest of the room
+func (room *hostedRoom) HandleConnections(w http.ResponseWriter, r *http.Request) {
+	// turn away wrong passwords with a plain HTTP error, the client shows the body to the user
+	if !room.checkPassword(r.Header.Get(passwordHeader)) {
+		fmt.Printf("rejected %s from %s: wrong password\n", room.path, r.RemoteAddr)
+		http.Error(w, "wrong room password", http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Printf("failed to upgrade connection from %s: %v\n", r.RemoteAddr, err)
+		return
+	}
+	defer ws.Close()
+
+	// ids are public, only the connection that presented the host token may act as the host
+	token := r.Header.Get(hostTokenHeader)
+	isHost := room.settings.HostToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(room.settings.HostToken)) == 1
+
+	// the client has to introduce itself with a compatible version before it is added to the room. Nobody else may use
+	// the host id, and an id already in the room only with the rejoin secret that connection was given
+	admit := func(id uint32, hello Hello) error {
+		if id == room.settings.HostID && !isHost {
+			return errors.New("client id is taken by the host")
+		}
+		return room.hub.Admit(id, hello.Rejoin)
+	}
+
+	welcome := Welcome{HostOnlyClear: room.settings.HostOnlyClear, HostID: room.settings.HostID, Rejoin: rand.Text()}
+	sender, hello, err := acceptHello(ws, welcome, admit)
+	if err != nil {
+		fmt.Printf("rejected client %s: %v\n", r.RemoteAddr, err)
+		return
+	}
+
+	// clients that didnt pick a nickname still get a name in the participant list
+	participant := Participant{ID: sender, Name: cleanNickname(hello.Name)}
+	if participant.Name == "" {
+		participant.Name = guestName(sender)
+	}
+
+	// where the client could serve the room if it takes over, on the address it reached us from. A client on our own
+	// machine reaches us over loopback, which nobody else could dial, so it cant take over
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && hello.Port != 0 {
+		if parsed := net.ParseIP(ip); parsed != nil && !parsed.IsLoopback() {
+			participant.Addr = net.JoinHostPort(ip, strconv.Itoa(hello.Port))
+		}
+	}
+
+	// the hub queues the whole canvas for the joiner before any live update
+	client, err := room.hub.Join(ws, participant, hello.Rejoin, welcome.Rejoin)
+	if err != nil {
+		fmt.Printf("failed to add client %s to %s: %v\n", r.RemoteAddr, room.path, err)
+		return
+	}
+	defer client.stop()
+
+	stop := keepAlive(ws)
+	defer stop()
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			timedOut := isTimeout(err)
+			if timedOut {
+				fmt.Printf("evicting client %d, no answer to pings for %s\n", sender, pongWait)
+			} else {
+				fmt.Printf("error reading message from ws: %v\n", err)
+			}
+			room.hub.Leave(client, timedOut)
+			break
+		}
+
+		var env Envelope
+		if err := env.UnmarshalBinary(msg); err != nil {
+			fmt.Printf("failed to read envelope in ws message: %v\n", err)
+			continue
+		}
+
+		// clients may only speak for themselves
+		if env.Version != ProtocolVersion || env.Sender != sender {
+			fmt.Printf("dropping message with version %d from sender %d on connection of %d\n", env.Version, env.Sender, sender)
+			continue
+		}
+
+		// echo the message back to its sender too, used when every client must apply it in the same order
+		echo := false
+
+		// validate the payload before relaying it so one bad client cant corrupt everyone elses canvas, and work out
+		// how it changes the host canvas
+		var apply func(c *Canvas)
+
+		switch env.Type {
+		case MsgStrokeDelta:
+			var delta StrokeDelta
+			if err := delta.UnmarshalBinary(env.Payload); err != nil {
+				fmt.Printf("failed to read stroke delta in ws message: %v\n", err)
+				continue
+			}
+			delta.Author = sender
+			apply = func(c *Canvas) { c.Merge(delta) }
+
+		case MsgUndo, MsgRedo:
+			id, err := parseStrokeRef(env.Payload)
+			if err != nil {
+				fmt.Printf("failed to read stroke reference in ws message: %v\n", err)
+				continue
+			}
+			undone := env.Type == MsgUndo
+			apply = func(c *Canvas) { c.SetUndone(sender, id, undone) }
+
+		case MsgSnapshot:
+			// replacing the room canvas is reserved to the host's own window
+			if !isHost {
+				fmt.Printf("dropping snapshot from client %d, only the host may open a canvas\n", sender)
+				continue
+			}
+
+			snapshot := NewCanvas()
+			if err := snapshot.UnmarshalBinary(env.Payload); err != nil {
+				fmt.Printf("failed to read canvas snapshot in ws message: %v\n", err)
+				continue
+			}
+			echo = true
+			apply = func(c *Canvas) { *c = *snapshot }
+
+		case MsgClear:
+			// the host's own window is connected as a regular client that presented the host token
+			if room.settings.HostOnlyClear && !isHost {
+				fmt.Printf("dropping clear from client %d, only the host may clear\n", sender)
+				continue
+			}
+			echo = true
+			apply = func(c *Canvas) { c.Clear() }
+
+		default:
+			fmt.Printf("unexpected %s message from client %d\n", env.Type, sender)
+			continue
+		}
+
+		// relay the message to every other client, the sender already applied it unless it is echoed
+		room.hub.Relay(client, msg, echo, apply)
+	}
+}
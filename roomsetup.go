@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// inputs of the form shown after clicking 'Make Room', in the order they appear
+const (
+	setupName = iota
+	setupTopic
+	setupTag
+	setupPassword
+)
+
+// 'Cancel' and 'Create' buttons under the room setup form
+func cancelButtonRect() rl.Rectangle {
+	return rl.NewRectangle((screenWidth/2)-250, float32(screenHeight)-230, float32(210), float32(100))
+}
+
+func createButtonRect() rl.Rectangle {
+	return rl.NewRectangle((screenWidth/2)+40, float32(screenHeight)-230, float32(210), float32(100))
+}
+
+// form describing the room we are about to make, the name defaults to the hostname
+func newRoomSetupForm() *Form {
+	hostName, _ := os.Hostname()
+
+	field := func(i int) rl.Rectangle {
+		return rl.NewRectangle((screenWidth/2)-300, float32(200+i*120), float32(600), float32(60))
+	}
+
+	return &Form{Inputs: []*TextInput{
+		setupName:     {Label: "Room name", Placeholder: "My room", Value: hostName, MaxLen: 24, Rect: field(setupName)},
+		setupTopic:    {Label: "Topic", Placeholder: "What are we drawing?", MaxLen: 48, Rect: field(setupTopic)},
+		setupTag:      {Label: "Tag (optional)", Placeholder: "e.g. doodles", MaxLen: 16, Rect: field(setupTag)},
+		setupPassword: {Label: "Password (optional)", Placeholder: "Anyone can join", MaxLen: 32, Masked: true, Rect: field(setupPassword)},
+	}}
+}
+
+// rounded outline button like the ones on the room config screen, blue when hovered
+func drawButton(font rl.Font, rec rl.Rectangle, text string, hovered bool) {
+	outline := rl.White
+	if hovered {
+		outline = rl.Blue
+	}
+
+	button := rl.NewRectangle(rec.X+5, rec.Y+5, rec.Width-10, rec.Height-10)
+	rl.DrawRectangleRounded(rec, float32(0.5), int32(0), outline)
+	rl.DrawRectangleRounded(button, float32(0.5), int32(0), rl.Black)
+
+	textMes := rl.MeasureTextEx(font, text, 40, 3)
+	rl.DrawTextEx(font, text, rl.NewVector2(rec.X+(rec.Width/2)-(textMes.X/2), rec.Y+(rec.Height/2)-(textMes.Y/2)), 40, 3, rl.White)
+}
+
+func (a *App) DrawRoomSetup() {
+	drawTextCentered(a.font.Regular, "Describe your room...", 80, 50, rl.White)
+
+	a.roomForm.Draw(a.font.Italic)
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	drawButton(a.font.BoldItalic, cancelButtonRect(), "Cancel", rl.CheckCollisionPointRec(mouse, cancelButtonRect()))
+	drawButton(a.font.BoldItalic, createButtonRect(), "Create", rl.CheckCollisionPointRec(mouse, createButtonRect()))
+
+	if a.joinErr != "" {
+		drawTextCentered(a.font.Italic, a.joinErr, screenHeight-100, 25, rl.Red)
+	} else if a.openCanvasPath != "" {
+		drawTextCentered(a.font.Italic, fmt.Sprintf("Your room will open %s", filepath.Base(a.openCanvasPath)), screenHeight-100, 25, rl.Gray)
+	} else {
+		drawTextCentered(a.font.Italic, "[Tab] next field   [Enter] create", screenHeight-100, 25, rl.DarkGray)
+	}
+}
+
+// type into the form, [Enter] or 'Create' makes the room and 'Cancel' goes back to the room options.
+// [M] and [Esc] are left alone here, one is typed into the fields and the other closes the window
+func (a *App) UpdateRoomSetup() {
+	a.GetMousePos()
+	a.OnFileDropped()
+
+	if a.isRoomHost && a.isServerBooted {
+		a.currentAppState = AppStateDrawStart
+		return
+	}
+
+	mouse := rl.NewVector2(a.mouseX, a.mouseY)
+	a.roomForm.Update(mouse)
+
+	if rl.IsMouseButtonReleased(rl.MouseButtonLeft) && rl.CheckCollisionPointRec(mouse, cancelButtonRect()) {
+		a.joinErr = ""
+		a.currentAppState = AppStateRoomConfig
+		return
+	}
+
+	create := rl.IsKeyPressed(rl.KeyEnter) || rl.IsKeyPressed(rl.KeyKpEnter)
+	if rl.IsMouseButtonReleased(rl.MouseButtonLeft) && rl.CheckCollisionPointRec(mouse, createButtonRect()) {
+		create = true
+	}
+
+	if create {
+		a.createRoomFromForm()
+	}
+}
+
+// make the room described by the setup form, starting from the saved canvas if one was picked
+func (a *App) createRoomFromForm() {
+	settings := RoomSettings{
+		Name:  a.roomForm.Inputs[setupName].Text(),
+		Topic: a.roomForm.Inputs[setupTopic].Text(),
+		Tag:   a.roomForm.Inputs[setupTag].Text(),
+
+		// not trimmed, spaces are part of the password
+		Password: a.roomForm.Inputs[setupPassword].Value,
+
+		HostOnlyClear: a.config.HostOnlyClear,
+	}
+
+	if settings.Name == "" {
+		a.joinErr = "Give your room a name"
+		a.roomForm.Focus = setupName
+		return
+	}
+
+	var canvas *Canvas
+	if a.openCanvasPath != "" {
+		var err error
+		canvas, err = LoadCanvasFile(a.openCanvasPath)
+		if err != nil {
+			// forget the file, otherwise every later attempt would trip over it again
+			fmt.Printf("failed to open canvas: %v\n", err)
+			a.joinErr = fmt.Sprintf("Could not open %s: %v", filepath.Base(a.openCanvasPath), err)
+			a.openCanvasPath = ""
+			return
+		}
+		a.openCanvasPath = ""
+	}
+
+	if err := a.MakeRoom(settings, canvas); err != nil {
+		fmt.Printf("failed to make room: %v\n", err)
+		a.joinErr = fmt.Sprintf("Could not make room: %v", err)
+		return
+	}
+
+	a.joinErr = ""
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// outgoing is a message waiting in the outbox for the sender. Points of a stroke stay a delta until they are sent, so
+// they can be extended while the stroke goes on and merged again after a reconnect
+type outgoing struct {
+	delta   *StrokeDelta // points of a stroke, nil for other messages
+	msgType MsgType
+	payload []byte
+}
+
+// add a local delta to the outbox, extending the last queued delta when it belongs to the same stroke. Caller must hold a.mu
+func (a *App) queueDelta(d StrokeDelta) {
+	if n := len(a.outbox); n > 0 {
+		if last := a.outbox[n-1].delta; last != nil && last.Author == d.Author && last.Stroke == d.Stroke && last.Seq+uint32(len(last.Points)) == d.Seq {
+			last.Points = append(last.Points, d.Points...)
+			return
+		}
+	}
+
+	a.outbox = append(a.outbox, outgoing{delta: &d})
+}
+
+// add any other message to the outbox, it is sent after the points queued before it. Caller must hold a.mu
+func (a *App) queueMessage(t MsgType, payload []byte) {
+	a.outbox = append(a.outbox, outgoing{msgType: t, payload: payload})
+}
+
+// send the outbox over c at the configured rate until the returned func is called. The render loop only ever queues,
+// so a slow network never freezes drawing, and this goroutine is the only writer of c apart from the pings
+func (a *App) startSender(c *websocket.Conn) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(time.Second / time.Duration(a.config.SendRate))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := a.flushOutbox(c); err != nil {
+					// the reader notices the closed connection and reconnects
+					fmt.Printf("failed to write to ws: %v\n", err)
+					c.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// write everything queued since the last tick, nothing is sent when nothing changed. Messages that couldnt be written
+// go back to the front of the outbox for after the reconnect
+func (a *App) flushOutbox(c *websocket.Conn) error {
+	a.mu.Lock()
+	items := a.outbox
+	a.outbox = nil
+	a.mu.Unlock()
+
+	for i, item := range items {
+		env := NewEnvelope(item.msgType, a.clientID, item.payload)
+		if item.delta != nil {
+			payload, err := item.delta.MarshalBinary()
+			if err != nil {
+				fmt.Printf("failed to write stroke delta to bytes: %v\n", err)
+				continue
+			}
+			env = NewEnvelope(MsgStrokeDelta, a.clientID, payload)
+		}
+
+		c.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := writeEnvelope(c, env); err != nil {
+			a.mu.Lock()
+			a.outbox = append(append([]outgoing{}, items[i:]...), a.outbox...)
+			a.mu.Unlock()
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// svg color attribute value, alpha is written separately as an opacity
+func svgColor(c rl.Color) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
+
+// WriteSVG converts strokes into an SVG document of the given size. Each stroke becomes a round capped <path> as wide as
+// the brush, single point strokes become a <circle>. Eraser strokes are painted with the background like on screen
+func WriteSVG(w io.Writer, strokes []*Stroke, width, height int) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(bw, `  <rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(canvasBackground))
+
+	for _, s := range strokes {
+		if s.Undone || len(s.Points) == 0 {
+			continue
+		}
+
+		color := s.InkColor()
+		opacity := ""
+		if color.A != 255 {
+			opacity = fmt.Sprintf(` opacity="%.3f"`, float32(color.A)/255)
+		}
+
+		if len(s.Points) == 1 {
+			p := s.Points[0]
+			fmt.Fprintf(bw, `  <circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"%s/>`+"\n", p.X, p.Y, s.Radius, svgColor(color), opacity)
+			continue
+		}
+
+		var d strings.Builder
+		for i, p := range s.Points {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&d, "%s%.1f %.1f ", cmd, p.X, p.Y)
+		}
+
+		fmt.Fprintf(bw, `  <path d="%s" fill="none" stroke="%s" stroke-width="%.1f" stroke-linecap="round" stroke-linejoin="round"%s/>`+"\n",
+			strings.TrimSpace(d.String()), svgColor(color), s.Radius*2, opacity)
+	}
+
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// write strokes to an SVG file at path
+func writeSVGFile(path string, strokes []*Stroke) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteSVG(f, strokes, screenWidth, screenHeight); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ConvertCanvasToSVG reads a saved canvas file and writes it as an SVG to svgPath. It doesnt need a window so it is
+// also used by the -svg command line option
+func ConvertCanvasToSVG(canvasPath, svgPath string) error {
+	canvas, err := LoadCanvasFile(canvasPath)
+	if err != nil {
+		return err
+	}
+
+	return writeSVGFile(svgPath, canvas.Strokes())
+}
+
+// ExportSVG writes the current canvas to a timestamped SVG in the working directory
+func (a *App) ExportSVG() (string, error) {
+	path := exportFileName("svg")
+
+	a.mu.RLock()
+	err := writeSVGFile(path, a.canvas.Strokes())
+	a.mu.RUnlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
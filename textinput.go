@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TextInput is a single line text field drawn with raylib
+type TextInput struct {
+	Label       string
+	Placeholder string
+	Value       string
+	MaxLen      int  // in runes
+	Masked      bool // draw the value as '*', for passwords
+	Rect        rl.Rectangle
+}
+
+// Form.Focus when no input has the keyboard
+const noFocus = -1
+
+// Form is a set of text inputs, at most one of them focused. [Tab] moves the focus, clicking an input focuses it
+type Form struct {
+	Inputs []*TextInput
+	Focus  int
+}
+
+// add typed characters, Ctrl+V pastes the clipboard and backspace (held or not) deletes
+func (t *TextInput) Update() {
+	for r := rl.GetCharPressed(); r > 0; r = rl.GetCharPressed() {
+		t.insert(string(rune(r)))
+	}
+
+	if isCtrlDown() && rl.IsKeyPressed(rl.KeyV) {
+		t.insert(rl.GetClipboardText())
+	}
+
+	if rl.IsKeyPressed(rl.KeyBackspace) || rl.IsKeyPressedRepeat(rl.KeyBackspace) {
+		if runes := []rune(t.Value); len(runes) > 0 {
+			t.Value = string(runes[:len(runes)-1])
+		}
+	}
+}
+
+// append printable characters up to MaxLen
+func (t *TextInput) insert(s string) {
+	for _, r := range s {
+		if r < 32 || r == 127 {
+			continue
+		}
+		if t.MaxLen > 0 && len([]rune(t.Value)) >= t.MaxLen {
+			return
+		}
+		t.Value += string(r)
+	}
+}
+
+// trimmed value
+func (t *TextInput) Text() string {
+	return strings.TrimSpace(t.Value)
+}
+
+// draw the label above a rounded field, blue outline and a blinking caret when focused
+func (t *TextInput) Draw(font rl.Font, focused bool) {
+	outline := rl.White
+	if focused {
+		outline = rl.Blue
+	}
+
+	container := rl.NewRectangle(t.Rect.X+3, t.Rect.Y+3, t.Rect.Width-6, t.Rect.Height-6)
+
+	rl.DrawTextEx(font, t.Label, rl.NewVector2(t.Rect.X+10, t.Rect.Y-35), 28, 2, rl.White)
+	rl.DrawRectangleRounded(t.Rect, float32(0.4), int32(0), outline)
+	rl.DrawRectangleRounded(container, float32(0.4), int32(0), rl.Black)
+
+	text := t.Value
+	if t.Masked {
+		text = strings.Repeat("*", len([]rune(t.Value)))
+	}
+
+	textPos := rl.NewVector2(t.Rect.X+20, t.Rect.Y+(t.Rect.Height/2)-15)
+
+	if text == "" && !focused {
+		rl.DrawTextEx(font, t.Placeholder, textPos, 30, 2, rl.DarkGray)
+		return
+	}
+
+	// blink the caret twice a second
+	if focused && math.Mod(rl.GetTime(), 1) < 0.5 {
+		text += "_"
+	}
+	rl.DrawTextEx(font, text, textPos, 30, 2, rl.White)
+}
+
+// the focused input, nil for an empty form
+func (f *Form) Focused() *TextInput {
+	if f.Focus < 0 || f.Focus >= len(f.Inputs) {
+		return nil
+	}
+	return f.Inputs[f.Focus]
+}
+
+// move the focus with [Tab] (Shift+Tab goes back) or a click, then type into the focused input
+func (f *Form) Update(mouse rl.Vector2) {
+	if len(f.Inputs) == 0 {
+		return
+	}
+
+	if rl.IsKeyPressed(rl.KeyTab) {
+		if isShiftDown() {
+			f.Focus = (f.Focus + len(f.Inputs) - 1) % len(f.Inputs)
+		} else {
+			f.Focus = (f.Focus + 1) % len(f.Inputs)
+		}
+	}
+
+	if rl.IsMouseButtonPressed(rl.MouseButtonLeft) {
+		for i, input := range f.Inputs {
+			if rl.CheckCollisionPointRec(mouse, input.Rect) {
+				f.Focus = i
+			}
+		}
+	}
+
+	if input := f.Focused(); input != nil {
+		input.Update()
+	}
+}
+
+func (f *Form) Draw(font rl.Font) {
+	for i, input := range f.Inputs {
+		input.Draw(font, i == f.Focus)
+	}
+}